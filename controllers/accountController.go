@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	helper "restaurant-management/helpers"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var mailer helper.Mailer = helper.NewMailer()
+
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 48 * time.Hour
+)
+
+// FORGOT PASSWORD
+func ForgotPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var input struct {
+			Email *string `json:"email" validate:"required,email"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// same answer whether or not the account exists, so this endpoint
+		// cannot be used to probe for emails
+		response := gin.H{"message": "if the account exists, a reset link has been sent"}
+
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"email": input.Email}).Decode(&user); err != nil {
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		token, err := helper.CreateUserToken(user.User_id, models.TokenPurposePasswordReset, passwordResetTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create reset token"})
+			return
+		}
+
+		err = mailer.Send(
+			*user.Email,
+			"Reset your password",
+			"Use the link below to choose a new password. It expires in one hour.\n\n"+
+				helper.AppLink("/reset-password", token),
+		)
+		if err != nil {
+			log.Println("password reset mail failed:", err)
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// RESET PASSWORD
+func ResetPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var input struct {
+			Token        *string `json:"token" validate:"required"`
+			New_password *string `json:"new_password" validate:"required,min=6"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userId, err := helper.ConsumeUserToken(*input.Token, models.TokenPurposePasswordReset)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := setPassword(ctx, userId, *input.New_password); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "password update failed"})
+			return
+		}
+
+		// the reset link proves the user owns the mailbox
+		var user models.User
+		err = userCollection.FindOneAndUpdate(
+			ctx,
+			bson.M{"user_id": userId},
+			bson.D{{"$set", bson.D{{"email_verified", true}}}},
+		).Decode(&user)
+		if err == nil && user.Email != nil {
+			helper.ResetLoginFailures(helper.AccountAttemptKey(*user.Email))
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "password reset, please log in"})
+	}
+}
+
+// VERIFY EMAIL
+func VerifyEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var input struct {
+			Token *string `json:"token" validate:"required"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userId, err := helper.ConsumeUserToken(*input.Token, models.TokenPurposeEmailVerify)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		_, err = userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": userId},
+			bson.D{{"$set", bson.D{
+				{"email_verified", true},
+				{"updated_at", time.Now()},
+			}}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "email verification failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "email verified, refresh your token to continue"})
+	}
+}
+
+// RESEND VERIFICATION EMAIL
+func ResendVerificationEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		if user.Email_verified {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email already verified"})
+			return
+		}
+
+		if err := sendVerificationEmail(user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not send verification email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "verification email sent"})
+	}
+}
+
+func sendVerificationEmail(user models.User) error {
+	token, err := helper.CreateUserToken(user.User_id, models.TokenPurposeEmailVerify, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(
+		*user.Email,
+		"Confirm your email address",
+		"Confirm your email address to start using your account.\n\n"+
+			helper.AppLink("/verify-email", token),
+	)
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"restaurant-management/database"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	pingOnce sync.Once
+	pingErr  error
+)
+
+// useTestDatabase points the controllers' collections at a scratch database
+// for the rest of the test, or skips the test when no mongod is reachable.
+func useTestDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	pingOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		pingErr = database.Client.Ping(ctx, nil)
+	})
+	if pingErr != nil {
+		t.Skip("no mongod reachable:", pingErr)
+	}
+
+	db := database.Client.Database("restaurant_test_" + primitive.NewObjectID().Hex())
+
+	collections := map[**mongo.Collection]string{
+		&deviceCollection:    database.CollectionDevices,
+		&foodCollection:      database.CollectionFoods,
+		&invoiceCollection:   database.CollectionInvoices,
+		&menuCollection:      database.CollectionMenus,
+		&noteCollection:      database.CollectionNotes,
+		&orderCollection:     database.CollectionOrders,
+		&orderItemCollection: database.CollectionOrderItems,
+		&stationCollection:   database.CollectionStations,
+		&settingCollection:   database.CollectionSettings,
+		&tableCollection:     database.CollectionTables,
+		&userCollection:      database.CollectionUsers,
+	}
+
+	for handle, name := range collections {
+		previous := *handle
+		*handle = db.Collection(name)
+		t.Cleanup(func() { *handle = previous })
+	}
+
+	t.Cleanup(func() {
+		db.Drop(context.Background())
+	})
+
+	return db
+}
+
+func insertAll(t *testing.T, collection *mongo.Collection, docs ...interface{}) {
+	t.Helper()
+
+	if _, err := collection.InsertMany(context.Background(), docs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serve runs a single handler the way the router would, with the given
+// values already set by the auth middleware.
+func serve(handler gin.HandlerFunc, method, route, target string, body interface{}, values gin.H) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(method, route, func(c *gin.Context) {
+		for key, value := range values {
+			c.Set(key, value)
+		}
+		handler(c)
+	})
+
+	var reader io.Reader
+	if body != nil {
+		out, _ := json.Marshal(body)
+		reader = bytes.NewReader(out)
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"time"
+
+	"restaurant-management/database"
+	helper "restaurant-management/helpers"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var deviceCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionDevices)
+
+var pinPattern = regexp.MustCompile(`^[0-9]{4,6}$`)
+
+// GET ALL DEVICES
+func GetDevices() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		cursor, err := deviceCollection.Find(ctx, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		devices := []models.Device{}
+		if err := cursor.All(ctx, &devices); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, devices)
+	}
+}
+
+// ENROLL DEVICE
+func CreateDevice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var device models.Device
+		if err := c.BindJSON(&device); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(device); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate device secret"})
+			return
+		}
+		secret := hex.EncodeToString(raw)
+
+		device.ID = primitive.NewObjectID()
+		device.Device_id = device.ID.Hex()
+		device.Secret_hash = helper.HashDeviceSecret(secret)
+		device.Created_by = c.GetString("uid")
+		device.Revoked = false
+		device.Revoked_at = nil
+		device.Last_used_at = nil
+		device.Created_at = time.Now()
+		device.Updated_at = time.Now()
+
+		if _, err := deviceCollection.InsertOne(ctx, device); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "device not created"})
+			return
+		}
+
+		// the secret is only ever shown here; the terminal sends it back in
+		// the X-Device-Secret header when staff sign in
+		c.JSON(http.StatusCreated, gin.H{
+			"device_id":     device.Device_id,
+			"name":          device.Name,
+			"device_secret": secret,
+		})
+	}
+}
+
+// REVOKE DEVICE
+func RevokeDevice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		deviceId := c.Param("device_id")
+		now := time.Now()
+
+		result, err := deviceCollection.UpdateOne(
+			ctx,
+			bson.M{"device_id": deviceId},
+			bson.D{{"$set", bson.D{
+				{"revoked", true},
+				{"revoked_at", now},
+				{"updated_at", now},
+			}}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "device revoke failed"})
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "device not found"})
+			return
+		}
+
+		revoked, err := helper.RevokeDeviceSessions(deviceId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not revoke device sessions"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"revoked_sessions": revoked})
+	}
+}
+
+// SET PIN
+func SetPin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		var input struct {
+			Pin *string `json:"pin" validate:"required"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !pinPattern.MatchString(*input.Pin) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pin must be 4 to 6 digits"})
+			return
+		}
+
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		// a PIN signs in as the user, so setting one is as good as owning
+		// the account
+		if outranksCaller(c, user) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you cannot set the PIN of a user with a higher role"})
+			return
+		}
+
+		hashed, err := bcrypt.GenerateFromPassword([]byte(*input.Pin), bcrypt.DefaultCost)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not set pin"})
+			return
+		}
+
+		result, err := userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": userId},
+			bson.D{{"$set", bson.D{
+				{"pin_hash", string(hashed)},
+				{"updated_at", time.Now()},
+			}}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not set pin"})
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "pin updated"})
+	}
+}
+
+// PIN LOGIN
+func PinLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var input struct {
+			User_id *string `json:"user_id" validate:"required"`
+			Pin     *string `json:"pin" validate:"required"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		deviceId := c.GetHeader(helper.DeviceIdHeader)
+		device, ok := helper.AuthenticateDevice(ctx, deviceId, c.GetHeader(helper.DeviceSecretHeader))
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unknown or revoked device"})
+			return
+		}
+
+		ip := c.ClientIP()
+		pinKey := helper.PinAttemptKey(device.Device_id, *input.User_id)
+		deviceKey := helper.DeviceAttemptKey(device.Device_id)
+
+		wait, err := helper.LoginBlockedFor(pinKey, deviceKey)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "login unavailable"})
+			return
+		}
+		if wait > 0 {
+			abortTooManyAttempts(c, wait)
+			return
+		}
+
+		var user models.User
+		err = userCollection.FindOne(ctx, bson.M{"user_id": input.User_id}).Decode(&user)
+		if err != nil || user.Pin_hash == nil ||
+			bcrypt.CompareHashAndPassword([]byte(*user.Pin_hash), []byte(*input.Pin)) != nil {
+			helper.RecordLoginFailure(ip, pinKey, deviceKey)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+			return
+		}
+
+		helper.ResetLoginFailures(pinKey)
+
+		if msg := inactiveAccountError(user); msg != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": msg})
+			return
+		}
+
+		// a PIN is no stronger than a password, so two-factor users still
+		// owe their code
+		if user.Totp_enabled {
+			challengeId, err := helper.CreateLoginChallenge(user.User_id, ip, device.Device_id)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not start two-factor login"})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"two_factor_required": true,
+				"challenge_id":        challengeId,
+			})
+			return
+		}
+
+		// nor may a PIN stand in for two-factor enrollment the role demands
+		if totpSetupRequired(user) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "your role requires two-factor authentication, sign in with your password to set it up"})
+			return
+		}
+
+		completeLogin(ctx, c, user, device.Device_id)
+	}
+}
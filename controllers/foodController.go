@@ -2,9 +2,11 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"restaurant-management/database"
@@ -17,7 +19,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var foodCollection *mongo.Collection = database.OpenCollection(database.Client, "food")
+var foodCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionFoods)
 
 /* ---------- helpers ---------- */
 
@@ -30,6 +32,73 @@ func toFixed(num float64, precision int) float64 {
 	return float64(round(num*output)) / output
 }
 
+// normalizeFoodSizes upper-cases size names, rounds their prices and rejects
+// duplicates.
+func normalizeFoodSizes(sizes []models.FoodSize) ([]models.FoodSize, error) {
+	seen := map[string]bool{}
+	normalized := []models.FoodSize{}
+
+	for _, size := range sizes {
+		name := strings.ToUpper(strings.TrimSpace(size.Size))
+		if seen[name] {
+			return nil, errors.New("size " + name + " is listed twice")
+		}
+		seen[name] = true
+
+		price := toFixed(*size.Price, 2)
+		normalized = append(normalized, models.FoodSize{Size: name, Price: &price})
+	}
+
+	return normalized, nil
+}
+
+// normalizeModifierGroups checks the selection rules of each group and gives
+// new groups and options their ids. Ids sent back by the client are kept, so
+// editing a group does not break the selections of orders in flight.
+func normalizeModifierGroups(groups []models.ModifierGroup) ([]models.ModifierGroup, error) {
+	seen := map[string]bool{}
+	normalized := []models.ModifierGroup{}
+
+	for _, group := range groups {
+		group.Name = strings.TrimSpace(group.Name)
+
+		if group.Max_select > 0 && group.Min_select > group.Max_select {
+			return nil, errors.New(group.Name + ": min_select is above max_select")
+		}
+		if group.Min_select > len(group.Options) {
+			return nil, errors.New(group.Name + ": min_select is above the number of options")
+		}
+
+		if group.Group_id == "" {
+			group.Group_id = primitive.NewObjectID().Hex()
+		}
+		if seen[group.Group_id] {
+			return nil, errors.New("modifier group " + group.Group_id + " is listed twice")
+		}
+		seen[group.Group_id] = true
+
+		options := []models.ModifierOption{}
+		for _, option := range group.Options {
+			option.Name = strings.TrimSpace(option.Name)
+			if option.Option_id == "" {
+				option.Option_id = primitive.NewObjectID().Hex()
+			}
+			if seen[option.Option_id] {
+				return nil, errors.New("modifier option " + option.Option_id + " is listed twice")
+			}
+			seen[option.Option_id] = true
+
+			option.Price_delta = toFixed(option.Price_delta, 2)
+			options = append(options, option)
+		}
+		group.Options = options
+
+		normalized = append(normalized, group)
+	}
+
+	return normalized, nil
+}
+
 /* ---------- controllers ---------- */
 
 // GET ALL FOODS
@@ -113,7 +182,6 @@ func CreateFood() gin.HandlerFunc {
 		defer cancel()
 
 		var food models.Food
-		var menu models.Menu
 
 		if err := c.BindJSON(&food); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -125,8 +193,7 @@ func CreateFood() gin.HandlerFunc {
 			return
 		}
 
-		if err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.Menu_id}).Decode(&menu); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "menu not found"})
+		if !validateReferences(ctx, c, menuRef("menu_id", food.Menu_id), stationRef("station_id", food.Station_id)) {
 			return
 		}
 
@@ -138,6 +205,20 @@ func CreateFood() gin.HandlerFunc {
 		price := toFixed(*food.Price, 2)
 		food.Price = &price
 
+		sizes, err := normalizeFoodSizes(food.Sizes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		food.Sizes = sizes
+
+		groups, err := normalizeModifierGroups(food.Modifier_groups)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		food.Modifier_groups = groups
+
 		result, err := foodCollection.InsertOne(ctx, food)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "food not created"})
@@ -156,7 +237,6 @@ func UpdateFood() gin.HandlerFunc {
 
 		foodId := c.Param("food_id")
 		var food models.Food
-		var menu models.Menu
 
 		if err := c.BindJSON(&food); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -174,18 +254,50 @@ func UpdateFood() gin.HandlerFunc {
 			updateObj = append(updateObj, bson.E{"price", price})
 		}
 
+		if food.Sizes != nil {
+			if err := validate.Var(food.Sizes, "dive"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			sizes, err := normalizeFoodSizes(food.Sizes)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"sizes", sizes})
+		}
+
+		if food.Modifier_groups != nil {
+			if err := validate.Var(food.Modifier_groups, "dive"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			groups, err := normalizeModifierGroups(food.Modifier_groups)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"modifier_groups", groups})
+		}
+
 		if food.Food_image != nil {
 			updateObj = append(updateObj, bson.E{"food_image", food.Food_image})
 		}
 
+		if !validateReferences(ctx, c, menuRef("menu_id", food.Menu_id), stationRef("station_id", food.Station_id)) {
+			return
+		}
+
 		if food.Menu_id != nil {
-			if err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.Menu_id}).Decode(&menu); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "menu not found"})
-				return
-			}
 			updateObj = append(updateObj, bson.E{"menu_id", food.Menu_id})
 		}
 
+		if food.Station_id != nil {
+			updateObj = append(updateObj, bson.E{"station_id", food.Station_id})
+		}
+
 		updateObj = append(updateObj, bson.E{"updated_at", time.Now()})
 
 		result, err := foodCollection.UpdateOne(
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"restaurant-management/database"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// at most this many ids are listed per check; the count is always exact
+const integritySampleSize = 100
+
+// IntegrityCheck is one kind of dangling reference found in stored data.
+type IntegrityCheck struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Count       int      `json:"count"`
+	Ids         []string `json:"ids"`
+}
+
+// orphanCheck finds documents of a collection (narrowed by filter) whose
+// refField matches no foreignKey in the from collection.
+type orphanCheck struct {
+	name        string
+	description string
+	collection  *mongo.Collection
+	filter      bson.M
+	idField     string
+	refField    string
+	from        string
+	foreignKey  string
+}
+
+var orphanChecks = []orphanCheck{
+	{"order_items_without_order", "order items whose order does not exist", orderItemCollection, bson.M{}, "order_item_id", "order_id", database.CollectionOrders, "order_id"},
+	{"order_items_without_food", "order items whose food does not exist", orderItemCollection, bson.M{}, "order_item_id", "food_id", database.CollectionFoods, "food_id"},
+	{"orders_without_table", "orders whose table does not exist", orderCollection, bson.M{}, "order_id", "table_id", database.CollectionTables, "table_id"},
+	{"foods_without_menu", "foods whose menu does not exist", foodCollection, bson.M{}, "food_id", "menu_id", database.CollectionMenus, "menu_id"},
+	{"invoices_without_order", "invoices whose order does not exist", invoiceCollection, bson.M{}, "invoice_id", "order_id", database.CollectionOrders, "order_id"},
+	{"notes_without_order", "order notes whose order does not exist", noteCollection, bson.M{"subject_type": models.NoteSubjectOrder}, "note_id", "subject_id", database.CollectionOrders, "order_id"},
+	{"notes_without_order_item", "item notes whose order item does not exist", noteCollection, bson.M{"subject_type": models.NoteSubjectOrderItem}, "note_id", "subject_id", database.CollectionOrderItems, "order_item_id"},
+	{"notes_without_table", "table notes whose table does not exist", noteCollection, bson.M{"subject_type": models.NoteSubjectTable}, "note_id", "subject_id", database.CollectionTables, "table_id"},
+}
+
+// GET INTEGRITY REPORT
+func GetIntegrityReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		report := []IntegrityCheck{}
+		total := 0
+
+		for _, check := range orphanChecks {
+			result, err := runOrphanCheck(ctx, check)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": check.name + ": " + err.Error()})
+				return
+			}
+			total += result.Count
+			report = append(report, result)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"checked_at": time.Now(),
+			"orphans":    total,
+			"checks":     report,
+		})
+	}
+}
+
+func runOrphanCheck(ctx context.Context, check orphanCheck) (IntegrityCheck, error) {
+	result := IntegrityCheck{Name: check.name, Description: check.description, Ids: []string{}}
+
+	pipeline := mongo.Pipeline{
+		{{"$match", check.filter}},
+		{{"$lookup", bson.M{
+			"from":         check.from,
+			"localField":   check.refField,
+			"foreignField": check.foreignKey,
+			"as":           "parent",
+		}}},
+		{{"$match", bson.M{"parent": bson.M{"$size": 0}}}},
+		{{"$facet", bson.M{
+			"count": bson.A{bson.M{"$count": "n"}},
+			"ids": bson.A{
+				bson.M{"$limit": integritySampleSize},
+				bson.M{"$project": bson.M{"_id": 0, "id": "$" + check.idField}},
+			},
+		}}},
+	}
+
+	cursor, err := check.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return result, err
+	}
+
+	var rows []struct {
+		Count []struct {
+			N int `bson:"n"`
+		} `bson:"count"`
+		Ids []struct {
+			Id string `bson:"id"`
+		} `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return result, err
+	}
+
+	if len(rows) > 0 {
+		if len(rows[0].Count) > 0 {
+			result.Count = rows[0].Count[0].N
+		}
+		for _, row := range rows[0].Ids {
+			result.Ids = append(result.Ids, row.Id)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Each check gets one child whose parent exists and one whose parent does
+// not; only the second may be reported.
+func TestOrphanChecks(t *testing.T) {
+	for _, check := range orphanChecks {
+		check := check
+
+		t.Run(check.name, func(t *testing.T) {
+			db := useTestDatabase(t)
+			check.collection = db.Collection(check.collection.Name())
+
+			child := func(id, ref string) bson.M {
+				doc := bson.M{check.idField: id, check.refField: ref}
+				for field, value := range check.filter {
+					doc[field] = value
+				}
+				return doc
+			}
+
+			insertAll(t, db.Collection(check.from), bson.M{check.foreignKey: "parent-1"})
+			insertAll(t, check.collection, child("linked", "parent-1"), child("orphan", "parent-9"))
+
+			result, err := runOrphanCheck(context.Background(), check)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Count != 1 || !reflect.DeepEqual(result.Ids, []string{"orphan"}) {
+				t.Errorf("got count %d ids %v, want the one orphan", result.Count, result.Ids)
+			}
+		})
+	}
+}
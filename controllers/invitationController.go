@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	helper "restaurant-management/helpers"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+// INVITE USER
+func InviteUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var input struct {
+			First_name *string `json:"first_name" validate:"required,min=2,max=100"`
+			Last_name  *string `json:"last_name" validate:"required,min=2,max=100"`
+			Email      *string `json:"email" validate:"required,email"`
+			Phone      *string `json:"phone" validate:"required"`
+			Role       *string `json:"role" validate:"required,eq=ADMIN|eq=MANAGER|eq=WAITER|eq=CASHIER|eq=KITCHEN"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		emailCount, _ := userCollection.CountDocuments(ctx, bson.M{"email": input.Email})
+		phoneCount, _ := userCollection.CountDocuments(ctx, bson.M{"phone": input.Phone})
+
+		if emailCount > 0 || phoneCount > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "email or phone already exists"})
+			return
+		}
+
+		status := models.UserStatusPending
+		user := models.User{
+			ID:         primitive.NewObjectID(),
+			First_name: input.First_name,
+			Last_name:  input.Last_name,
+			Email:      input.Email,
+			Phone:      input.Phone,
+			Role:       input.Role,
+			Status:     &status,
+			Created_at: time.Now(),
+			Updated_at: time.Now(),
+		}
+		user.User_id = user.ID.Hex()
+
+		if _, err := userCollection.InsertOne(ctx, user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not created"})
+			return
+		}
+
+		if err := sendInvitation(user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "user created but the invitation could not be sent"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, newUserAdminView(user))
+	}
+}
+
+// RESEND INVITATION
+func ResendInvitation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{
+			"user_id": c.Param("user_id"),
+			"status":  models.UserStatusPending,
+		}).Decode(&user)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no pending invitation for this user"})
+			return
+		}
+
+		if err := sendInvitation(user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not send invitation"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "invitation sent"})
+	}
+}
+
+// ACCEPT INVITATION
+func AcceptInvitation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var input struct {
+			Token    *string `json:"token" validate:"required"`
+			Password *string `json:"password" validate:"required,min=6"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userId, err := helper.ConsumeUserToken(*input.Token, models.TokenPurposeInvitation)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// the link arrived in the invitee's mailbox, which verifies it
+		result, err := userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": userId, "status": models.UserStatusPending},
+			bson.D{{"$set", bson.D{
+				{"password", HashPassword(*input.Password)},
+				{"status", models.UserStatusActive},
+				{"email_verified", true},
+				{"updated_at", time.Now()},
+			}}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not activate account"})
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invitation is no longer pending"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "account activated, please log in"})
+	}
+}
+
+func sendInvitation(user models.User) error {
+	token, err := helper.CreateUserToken(user.User_id, models.TokenPurposeInvitation, invitationTTL)
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(
+		*user.Email,
+		"You have been invited",
+		"Hi "+*user.First_name+",\n\nAn account has been created for you. Choose a password "+
+			"using the link below; it works once and expires in seven days.\n\n"+
+			helper.AppLink("/accept-invitation", token),
+	)
+}
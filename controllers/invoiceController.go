@@ -15,17 +15,18 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var invoiceCollection *mongo.Collection = database.OpenCollection(database.Client, "invoices")
+var invoiceCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionInvoices)
 
 type InvoiceViewFormat struct {
-	Invoice_id       string      `json:"invoice_id"`
-	Payment_method   string      `json:"payment_method"`
-	Order_id         string      `json:"order_id"`
-	Payment_status   *string     `json:"payment_status"`
-	Payment_due      interface{} `json:"payment_due"`
-	Table_number     interface{} `json:"table_number"`
-	Payment_due_date time.Time   `json:"payment_due_date"`
-	Order_details    interface{} `json:"order_details"`
+	Invoice_id       string        `json:"invoice_id"`
+	Payment_method   string        `json:"payment_method"`
+	Order_id         string        `json:"order_id"`
+	Payment_status   *string       `json:"payment_status"`
+	Payment_due      float64       `json:"payment_due"`
+	Table_number     *int          `json:"table_number"`
+	Payment_due_date time.Time     `json:"payment_due_date"`
+	Order_details    []InvoiceLine `json:"order_details"`
+	Notes            []models.Note `json:"notes"`
 }
 
 /* ================= GET ALL INVOICES ================= */
@@ -64,8 +65,12 @@ func GetInvoiceById() gin.HandlerFunc {
 			return
 		}
 
-		allOrderItems, err := ItemsByOrder(invoice.Order_id)
-		if err != nil || len(allOrderItems) == 0 {
+		detail, err := ItemsByOrder(invoice.Order_id)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order of this invoice not found"})
+			return
+		}
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "order details not found"})
 			return
 		}
@@ -76,15 +81,23 @@ func GetInvoiceById() gin.HandlerFunc {
 			Payment_due_date: invoice.Payment_due_date,
 			Payment_status:   invoice.Payment_status,
 			Payment_method:   "N/A",
-			Payment_due:      allOrderItems[0]["payment_due"],
-			Table_number:     allOrderItems[0]["table_number"],
-			Order_details:    allOrderItems[0]["order_items"],
+			Payment_due:      detail.Payment_due,
+			Table_number:     detail.Table_number,
+			Order_details:    detail.Order_items,
 		}
 
 		if invoice.Payment_method != nil {
 			view.Payment_method = *invoice.Payment_method
 		}
 
+		var order models.Order
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order); err == nil {
+			if view.Notes, err = notesForOrder(ctx, order); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load order notes"})
+				return
+			}
+		}
+
 		c.JSON(http.StatusOK, view)
 	}
 }
@@ -108,6 +121,11 @@ func CreateInvoice() gin.HandlerFunc {
 			return
 		}
 
+		if order.CurrentStatus() != models.OrderStatusServed {
+			c.JSON(http.StatusConflict, gin.H{"error": "only served orders can be invoiced, order is " + order.CurrentStatus()})
+			return
+		}
+
 		if invoice.Payment_status == nil {
 			status := "PENDING"
 			invoice.Payment_status = &status
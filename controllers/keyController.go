@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"net/http"
+
+	helper "restaurant-management/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET JWKS
+func GetJWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jwks, err := helper.JWKS()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "signing keys unavailable"})
+			return
+		}
+
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, jwks)
+	}
+}
+
+// ROTATE SIGNING KEY
+func RotateSigningKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kid, err := helper.RotateSigningKey()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "key rotation failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"kid": kid})
+	}
+}
@@ -0,0 +1,386 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	helper "restaurant-management/helpers"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// how often a stream looks for events published by other instances
+	kitchenPollInterval = 2 * time.Second
+	// keeps proxies from closing an idle stream
+	kitchenHeartbeatInterval = 15 * time.Second
+	kitchenEventBatch        = 100
+	kitchenRetryMillis       = 3000
+)
+
+// KitchenTicket is what the kitchen prints or shows for an order: the items
+// to cook and every note that affects how they are prepared or served.
+type KitchenTicket struct {
+	Order_id     string              `json:"order_id"`
+	Table_id     *string             `json:"table_id"`
+	Table_number *int                `json:"table_number"`
+	Ordered_at   time.Time           `json:"ordered_at"`
+	Notes        []models.Note       `json:"notes"`
+	Items        []KitchenTicketItem `json:"items"`
+}
+
+type KitchenTicketItem struct {
+	Order_item_id string                    `json:"order_item_id"`
+	Food_id       *string                   `json:"food_id"`
+	Food_name     *string                   `json:"food_name"`
+	Quantity      *int                      `json:"quantity"`
+	Size          *string                   `json:"size"`
+	Modifiers     []models.SelectedModifier `json:"modifiers"`
+	Station_id    string                    `json:"station_id"`
+	Course        int                       `json:"course"`
+	Held          bool                      `json:"held"`
+	Prep_status   string                    `json:"prep_status"`
+	Notes         []models.Note             `json:"notes"`
+}
+
+// STREAM KITCHEN EVENTS
+func StreamKitchen() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lastSeq, err := kitchenResumePoint(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Last-Event-ID"})
+			return
+		}
+
+		// a station display only gets its own tickets
+		stationId, byStation := c.GetQuery("station_id")
+
+		wake, stop := helper.ListenKitchenEvents()
+		defer stop()
+
+		poll := time.NewTicker(kitchenPollInterval)
+		defer poll.Stop()
+		heartbeat := time.NewTicker(kitchenHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		fmt.Fprintf(c.Writer, "retry: %d\n\n", kitchenRetryMillis)
+		c.Writer.Flush()
+
+		for {
+			events, err := helper.KitchenEventsAfter(lastSeq, kitchenEventBatch)
+			if err != nil {
+				// the display reconnects and resumes from its Last-Event-ID
+				log.Println("kitchen stream:", err)
+				return
+			}
+
+			for _, event := range events {
+				lastSeq = event.Seq
+				if byStation && event.Item.Station_id != stationId {
+					continue
+				}
+				if err := writeKitchenEvent(c.Writer, event); err != nil {
+					return
+				}
+			}
+
+			if len(events) > 0 {
+				c.Writer.Flush()
+			}
+			if len(events) == kitchenEventBatch {
+				continue
+			}
+
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-wake:
+			case <-poll.C:
+			case <-heartbeat.C:
+				fmt.Fprint(c.Writer, ": ping\n\n")
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+// GET KITCHEN QUEUE
+func GetKitchenQueue() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{"prep_status": bson.M{"$nin": bson.A{models.PrepStatusReady, models.PrepStatusCancelled}}}
+		if prepStatus := c.Query("prep_status"); prepStatus != "" {
+			filter = bson.M{"prep_status": prepStatus}
+		}
+		filter["held"] = bson.M{"$ne": true}
+		if stationId, ok := c.GetQuery("station_id"); ok {
+			filter["station_id"] = stationId
+		}
+
+		cursor, err := orderItemCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": 1}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		items := []models.OrderItem{}
+		if err := cursor.All(ctx, &items); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// the feed position to resume from once this snapshot is on screen
+		seq, err := helper.LatestKitchenEventSeq()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"last_event_id": seq,
+			"order_items":   items,
+		})
+	}
+}
+
+// START ORDER ITEM
+func StartOrderItem() gin.HandlerFunc {
+	return changePrepStatus(
+		[]string{models.PrepStatusQueued},
+		models.PrepStatusPreparing,
+		models.KitchenEventItemUpdated,
+	)
+}
+
+// BUMP ORDER ITEM
+func BumpOrderItem() gin.HandlerFunc {
+	return changePrepStatus(
+		[]string{models.PrepStatusQueued, models.PrepStatusPreparing},
+		models.PrepStatusReady,
+		models.KitchenEventItemBumped,
+	)
+}
+
+// RECALL ORDER ITEM
+func RecallOrderItem() gin.HandlerFunc {
+	return changePrepStatus(
+		[]string{models.PrepStatusReady},
+		models.PrepStatusPreparing,
+		models.KitchenEventItemRecalled,
+	)
+}
+
+func changePrepStatus(from []string, to, eventType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orderItemId := c.Param("order_item_id")
+
+		statuses := bson.A{}
+		for _, status := range from {
+			statuses = append(statuses, status)
+			if status == models.PrepStatusQueued {
+				// items from before the kitchen display have no prep status yet
+				statuses = append(statuses, nil)
+			}
+		}
+		filter := bson.M{"order_item_id": orderItemId, "prep_status": bson.M{"$in": statuses}, "held": bson.M{"$ne": true}}
+
+		set := bson.D{{"prep_status", to}, {"updated_at", time.Now()}}
+		if to == models.PrepStatusReady {
+			set = append(set, bson.E{"bumped_at", time.Now()})
+		}
+
+		var item models.OrderItem
+		err := orderItemCollection.FindOneAndUpdate(
+			ctx,
+			filter,
+			bson.D{{"$set", set}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&item)
+
+		if err == mongo.ErrNoDocuments {
+			if err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&item); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "order item not found"})
+				return
+			}
+			if item.Held {
+				c.JSON(http.StatusConflict, gin.H{"error": "order item is held until its course is fired"})
+				return
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "order item is " + item.CurrentPrepStatus()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "prep status update failed"})
+			return
+		}
+
+		publishKitchenEvent(eventType, item)
+
+		c.JSON(http.StatusOK, item)
+	}
+}
+
+// GET KITCHEN TICKET
+func GetKitchenTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var order models.Order
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": c.Param("order_id")}).Decode(&order); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+			return
+		}
+
+		ticket, err := kitchenTicket(ctx, order)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, ticket)
+	}
+}
+
+func kitchenTicket(ctx context.Context, order models.Order) (KitchenTicket, error) {
+	ticket := KitchenTicket{
+		Order_id:   order.Order_id,
+		Table_id:   order.Table_id,
+		Ordered_at: order.Created_at,
+		Notes:      []models.Note{},
+		Items:      []KitchenTicketItem{},
+	}
+
+	if order.Table_id != nil {
+		var table models.Table
+		if err := tableCollection.FindOne(ctx, bson.M{"table_id": *order.Table_id}).Decode(&table); err == nil {
+			ticket.Table_number = table.Table_number
+		}
+	}
+
+	cursor, err := orderItemCollection.Find(ctx, bson.M{"order_id": order.Order_id})
+	if err != nil {
+		return ticket, err
+	}
+
+	var items []models.OrderItem
+	if err := cursor.All(ctx, &items); err != nil {
+		return ticket, err
+	}
+
+	foodIds := bson.A{}
+	for _, item := range items {
+		if item.Food_id != nil {
+			foodIds = append(foodIds, *item.Food_id)
+		}
+	}
+
+	foodNames := map[string]*string{}
+	if len(foodIds) > 0 {
+		cursor, err := foodCollection.Find(ctx, bson.M{"food_id": bson.M{"$in": foodIds}})
+		if err != nil {
+			return ticket, err
+		}
+
+		var foods []models.Food
+		if err := cursor.All(ctx, &foods); err != nil {
+			return ticket, err
+		}
+
+		for _, food := range foods {
+			foodNames[food.Food_id] = food.Name
+		}
+	}
+
+	notes, err := notesForOrder(ctx, order)
+	if err != nil {
+		return ticket, err
+	}
+
+	itemNotes := map[string][]models.Note{}
+	for _, note := range notes {
+		if *note.Subject_type == models.NoteSubjectOrderItem {
+			itemNotes[*note.Subject_id] = append(itemNotes[*note.Subject_id], note)
+		} else {
+			ticket.Notes = append(ticket.Notes, note)
+		}
+	}
+
+	for _, item := range items {
+		ticketItem := KitchenTicketItem{
+			Order_item_id: item.Order_item_id,
+			Food_id:       item.Food_id,
+			Quantity:      item.Quantity,
+			Size:          item.Size,
+			Modifiers:     item.Modifiers,
+			Station_id:    item.Station_id,
+			Course:        item.CurrentCourse(),
+			Held:          item.Held,
+			Prep_status:   item.CurrentPrepStatus(),
+			Notes:         itemNotes[item.Order_item_id],
+		}
+		if item.Food_name != nil {
+			ticketItem.Food_name = item.Food_name
+		} else if item.Food_id != nil {
+			ticketItem.Food_name = foodNames[*item.Food_id]
+		}
+		if ticketItem.Notes == nil {
+			ticketItem.Notes = []models.Note{}
+		}
+		ticket.Items = append(ticket.Items, ticketItem)
+	}
+
+	return ticket, nil
+}
+
+// publishKitchenEvent feeds the kitchen display. The write it reports on has
+// already happened, so a failure is logged rather than failing the request.
+func publishKitchenEvent(eventType string, item models.OrderItem) {
+	if err := helper.PublishKitchenEvent(eventType, item); err != nil {
+		log.Println("kitchen event", eventType, item.Order_item_id+":", err)
+	}
+}
+
+func kitchenResumePoint(c *gin.Context) (int64, error) {
+	lastEventId := c.GetHeader("Last-Event-ID")
+	if lastEventId == "" {
+		// EventSource cannot set headers on its first connection
+		lastEventId = c.Query("last_event_id")
+	}
+
+	if lastEventId == "" {
+		return helper.LatestKitchenEventSeq()
+	}
+
+	return strconv.ParseInt(lastEventId, 10, 64)
+}
+
+func writeKitchenEvent(w io.Writer, event models.KitchenEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)
+	return err
+}
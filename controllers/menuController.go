@@ -14,8 +14,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var menuCollection *mongo.Collection =
-	database.OpenCollection(database.Client, "menus")
+var menuCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionMenus)
 
 // GET ALL MENUS
 func GetMenus() gin.HandlerFunc {
@@ -79,6 +78,10 @@ func CreateMenu() gin.HandlerFunc {
 			return
 		}
 
+		if !validateReferences(ctx, c, stationRef("default_station_id", menu.Default_station_id)) {
+			return
+		}
+
 		menu.ID = primitive.NewObjectID()
 		menu.Menu_id = menu.ID.Hex()
 		menu.Created_at = time.Now()
@@ -121,6 +124,12 @@ func UpdateMenu() gin.HandlerFunc {
 		if input.End_Date != nil {
 			updateObj = append(updateObj, bson.E{"end_date", input.End_Date})
 		}
+		if input.Default_station_id != nil {
+			if !validateReferences(ctx, c, stationRef("default_station_id", input.Default_station_id)) {
+				return
+			}
+			updateObj = append(updateObj, bson.E{"default_station_id", input.Default_station_id})
+		}
 
 		if len(updateObj) == 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
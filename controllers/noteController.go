@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"restaurant-management/database"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var noteCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionNotes)
+
+// GET ALL NOTES
+func GetNotes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if subjectType := c.Query("subject_type"); subjectType != "" {
+			filter["subject_type"] = strings.ToUpper(subjectType)
+		}
+		if subjectId := c.Query("subject_id"); subjectId != "" {
+			filter["subject_id"] = subjectId
+		}
+
+		cursor, err := noteCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": 1}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		notes := []models.Note{}
+		if err := cursor.All(ctx, &notes); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, notes)
+	}
+}
+
+// GET NOTE BY ID
+func GetNoteById() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var note models.Note
+		if err := noteCollection.FindOne(ctx, bson.M{"note_id": c.Param("note_id")}).Decode(&note); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "note not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, note)
+	}
+}
+
+// CREATE NOTE
+func CreateNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var note models.Note
+		if err := c.BindJSON(&note); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if note.Subject_type != nil {
+			subjectType := strings.ToUpper(*note.Subject_type)
+			note.Subject_type = &subjectType
+		}
+
+		if err := validate.Struct(note); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !noteSubjectExists(ctx, *note.Subject_type, *note.Subject_id) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": strings.ToLower(*note.Subject_type) + " not found"})
+			return
+		}
+
+		note.ID = primitive.NewObjectID()
+		note.Note_id = note.ID.Hex()
+		note.Author_id = c.GetString("uid")
+		note.Author_name = strings.TrimSpace(c.GetString("first_name") + " " + c.GetString("last_name"))
+		note.Created_at = time.Now()
+		note.Updated_at = time.Now()
+
+		if _, err := noteCollection.InsertOne(ctx, note); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "note not created"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, note)
+	}
+}
+
+// UPDATE NOTE
+func UpdateNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		noteId := c.Param("note_id")
+
+		var input struct {
+			Title *string `json:"title" validate:"omitempty,max=100"`
+			Text  *string `json:"text" validate:"omitempty,min=1,max=1000"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !canEditNote(ctx, c, noteId) {
+			return
+		}
+
+		var updateObj primitive.D
+
+		if input.Title != nil {
+			updateObj = append(updateObj, bson.E{"title", input.Title})
+		}
+		if input.Text != nil {
+			updateObj = append(updateObj, bson.E{"text", input.Text})
+		}
+
+		if len(updateObj) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+			return
+		}
+
+		updateObj = append(updateObj, bson.E{"updated_at", time.Now()})
+
+		result, err := noteCollection.UpdateOne(
+			ctx,
+			bson.M{"note_id": noteId},
+			bson.D{{"$set", updateObj}},
+		)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "note update failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// DELETE NOTE
+func DeleteNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		noteId := c.Param("note_id")
+
+		if !canEditNote(ctx, c, noteId) {
+			return
+		}
+
+		if _, err := noteCollection.DeleteOne(ctx, bson.M{"note_id": noteId}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "note not deleted"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "note deleted"})
+	}
+}
+
+// canEditNote lets the author change their own note and managers change any.
+// It writes the error response itself.
+func canEditNote(ctx context.Context, c *gin.Context, noteId string) bool {
+	var note models.Note
+	if err := noteCollection.FindOne(ctx, bson.M{"note_id": noteId}).Decode(&note); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "note not found"})
+		return false
+	}
+
+	role := c.GetString("role")
+	if note.Author_id != c.GetString("uid") && role != models.RoleAdmin && role != models.RoleManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the author or a manager can change this note"})
+		return false
+	}
+
+	return true
+}
+
+func noteSubjectExists(ctx context.Context, subjectType, subjectId string) bool {
+	var collection *mongo.Collection
+	var filter bson.M
+
+	switch subjectType {
+	case models.NoteSubjectOrder:
+		collection, filter = orderCollection, bson.M{"order_id": subjectId}
+	case models.NoteSubjectOrderItem:
+		collection, filter = orderItemCollection, bson.M{"order_item_id": subjectId}
+	case models.NoteSubjectTable:
+		collection, filter = tableCollection, bson.M{"table_id": subjectId}
+	default:
+		return true
+	}
+
+	count, err := collection.CountDocuments(ctx, filter)
+	return err == nil && count > 0
+}
+
+// notesForOrder gathers everything staff wrote about an order: notes on the
+// order itself, on any of its items, on the table it was placed at and on
+// the guest it is for.
+func notesForOrder(ctx context.Context, order models.Order) ([]models.Note, error) {
+	subjects := bson.A{bson.M{"subject_type": models.NoteSubjectOrder, "subject_id": order.Order_id}}
+
+	if order.Table_id != nil {
+		subjects = append(subjects, bson.M{"subject_type": models.NoteSubjectTable, "subject_id": *order.Table_id})
+	}
+	if order.Customer_id != nil && *order.Customer_id != "" {
+		subjects = append(subjects, bson.M{"subject_type": models.NoteSubjectCustomer, "subject_id": *order.Customer_id})
+	}
+
+	itemIds, err := orderItemCollection.Distinct(ctx, "order_item_id", bson.M{"order_id": order.Order_id})
+	if err != nil {
+		return nil, err
+	}
+	if len(itemIds) > 0 {
+		subjects = append(subjects, bson.M{"subject_type": models.NoteSubjectOrderItem, "subject_id": bson.M{"$in": itemIds}})
+	}
+
+	cursor, err := noteCollection.Find(ctx, bson.M{"$or": subjects}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	notes := []models.Note{}
+	if err := cursor.All(ctx, &notes); err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// insertNotedOrder stores an order for a known guest with a note on every
+// kind of subject it can pick up.
+func insertNotedOrder(t *testing.T) {
+	t.Helper()
+
+	now := time.Now()
+	insertAll(t, tableCollection, bson.M{"table_id": "table-1", "table_number": 7})
+	insertAll(t, orderCollection, bson.M{"order_id": "order-1", "table_id": "table-1", "customer_id": "555-0100", "created_at": now})
+	insertAll(t, orderItemCollection, bson.M{"order_item_id": "item-1", "order_id": "order-1", "food_name": "Cake", "unit_price": 6.0, "quantity": 1})
+	insertAll(t, invoiceCollection, bson.M{"invoice_id": "invoice-1", "order_id": "order-1", "payment_status": "PENDING"})
+	insertAll(t, noteCollection,
+		bson.M{"note_id": "on-order", "subject_type": models.NoteSubjectOrder, "subject_id": "order-1", "text": "window seat", "created_at": now},
+		bson.M{"note_id": "on-item", "subject_type": models.NoteSubjectOrderItem, "subject_id": "item-1", "text": "no nuts", "created_at": now.Add(time.Second)},
+		bson.M{"note_id": "on-table", "subject_type": models.NoteSubjectTable, "subject_id": "table-1", "text": "wobbly", "created_at": now.Add(2 * time.Second)},
+		bson.M{"note_id": "on-customer", "subject_type": models.NoteSubjectCustomer, "subject_id": "555-0100", "text": "allergic to peanuts", "created_at": now.Add(3 * time.Second)},
+		bson.M{"note_id": "other-customer", "subject_type": models.NoteSubjectCustomer, "subject_id": "555-0199", "text": "birthday", "created_at": now.Add(4 * time.Second)},
+	)
+}
+
+func noteIds(notes []models.Note) map[string]bool {
+	ids := map[string]bool{}
+	for _, note := range notes {
+		ids[note.Note_id] = true
+	}
+	return ids
+}
+
+func assertNoteIds(t *testing.T, view string, got map[string]bool, want ...string) {
+	t.Helper()
+
+	for _, id := range want {
+		if !got[id] {
+			t.Errorf("%s is missing note %s, got %v", view, id, got)
+		}
+	}
+	if got["other-customer"] {
+		t.Errorf("%s shows a note about another guest", view)
+	}
+}
+
+var waiter = gin.H{"uid": "waiter-1", "role": models.RoleWaiter}
+
+func TestOrderViewShowsNotes(t *testing.T) {
+	useTestDatabase(t)
+	insertNotedOrder(t)
+
+	rec := serve(GetOrderById(), http.MethodGet, "/orders/:order_id", "/orders/order-1", nil, waiter)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+
+	var view OrderView
+	if err := json.Unmarshal(rec.Body.Bytes(), &view); err != nil {
+		t.Fatal(err)
+	}
+	assertNoteIds(t, "order view", noteIds(view.Notes), "on-order", "on-item", "on-table", "on-customer")
+}
+
+func TestInvoiceViewShowsNotes(t *testing.T) {
+	useTestDatabase(t)
+	insertNotedOrder(t)
+
+	rec := serve(GetInvoiceById(), http.MethodGet, "/invoices/:invoice_id", "/invoices/invoice-1", nil, waiter)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+
+	var view InvoiceViewFormat
+	if err := json.Unmarshal(rec.Body.Bytes(), &view); err != nil {
+		t.Fatal(err)
+	}
+	assertNoteIds(t, "invoice view", noteIds(view.Notes), "on-order", "on-item", "on-table", "on-customer")
+}
+
+func TestKitchenTicketShowsNotes(t *testing.T) {
+	useTestDatabase(t)
+	insertNotedOrder(t)
+
+	rec := serve(GetKitchenTicket(), http.MethodGet, "/kitchen/tickets/:order_id", "/kitchen/tickets/order-1", nil, waiter)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+
+	var ticket KitchenTicket
+	if err := json.Unmarshal(rec.Body.Bytes(), &ticket); err != nil {
+		t.Fatal(err)
+	}
+
+	// item notes travel with their item, the rest head the ticket
+	assertNoteIds(t, "kitchen ticket", noteIds(ticket.Notes), "on-order", "on-table", "on-customer")
+	if len(ticket.Items) != 1 {
+		t.Fatalf("got %d items, want 1", len(ticket.Items))
+	}
+	assertNoteIds(t, "kitchen ticket item", noteIds(ticket.Items[0].Notes), "on-item")
+}
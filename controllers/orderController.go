@@ -3,6 +3,9 @@ package controllers
 import (
 	"context"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"restaurant-management/database"
@@ -12,10 +15,35 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var orderCollection *mongo.Collection =
-	database.OpenCollection(database.Client, "orders")
+var orderCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionOrders)
+
+// OrderView is an order together with the notes staff left on it, its items
+// and its table, and where each of its courses stands.
+type OrderView struct {
+	models.Order
+	Notes           []models.Note `json:"notes"`
+	Courses         []OrderCourse `json:"courses"`
+	Pending_courses []int         `json:"pending_courses"`
+}
+
+// OrderCourse summarizes one course of an order. A course is HELD while any
+// of its items waits to be fired and READY once the kitchen bumped them all.
+type OrderCourse struct {
+	Course int    `json:"course"`
+	Items  int    `json:"items"`
+	Held   int    `json:"held"`
+	Ready  int    `json:"ready"`
+	Status string `json:"status"`
+}
+
+const (
+	CourseStatusHeld  = "HELD"
+	CourseStatusFired = "FIRED"
+	CourseStatusReady = "READY"
+)
 
 // GET ALL ORDERS
 func GetOrders() gin.HandlerFunc {
@@ -58,7 +86,25 @@ func GetOrderById() gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(http.StatusOK, order)
+		notes, err := notesForOrder(ctx, order)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load order notes"})
+			return
+		}
+
+		view := OrderView{Order: order, Notes: notes, Pending_courses: []int{}}
+		if view.Courses, err = orderCourses(ctx, orderId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load order courses"})
+			return
+		}
+
+		for _, course := range view.Courses {
+			if course.Status == CourseStatusHeld {
+				view.Pending_courses = append(view.Pending_courses, course.Course)
+			}
+		}
+
+		c.JSON(http.StatusOK, view)
 	}
 }
 
@@ -68,23 +114,34 @@ func CreateOrder() gin.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		var order models.Order
-		if err := c.BindJSON(&order); err != nil {
+		// status, merges and moves are the server's to record, so the client
+		// only picks the table and the guest
+		var input struct {
+			Table_id    *string `json:"table_id"`
+			Customer_id *string `json:"customer_id"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// Only validate fields provided by client
-		if order.Table_id == nil {
+		if input.Table_id == nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "table_id is required"})
 			return
 		}
 
+		if !validateReferences(ctx, c, tableRef("table_id", input.Table_id)) {
+			return
+		}
+
+		order := models.Order{Table_id: input.Table_id, Customer_id: input.Customer_id}
 		order.ID = primitive.NewObjectID()
 		order.Order_id = order.ID.Hex()
 		order.Order_Date = time.Now()
 		order.Created_at = time.Now()
 		order.Updated_at = time.Now()
+		placeOrder(&order, c.GetString("uid"))
 
 		result, err := orderCollection.InsertOne(ctx, order)
 		if err != nil {
@@ -92,6 +149,8 @@ func CreateOrder() gin.HandlerFunc {
 			return
 		}
 
+		updateTableOccupancy(ctx, order.Table_id)
+
 		c.JSON(http.StatusCreated, result)
 	}
 }
@@ -104,35 +163,274 @@ func UpdateOrder() gin.HandlerFunc {
 
 		orderId := c.Param("order_id")
 		var input models.Order
-		var updateObj primitive.D
 
 		if err := c.BindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		if input.Table_id != nil {
-			updateObj = append(updateObj, bson.E{"table_id", input.Table_id})
+		if input.Table_id == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+			return
 		}
 
-		if len(updateObj) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		if !validateReferences(ctx, c, tableRef("table_id", input.Table_id)) {
+			return
+		}
+
+		var order models.Order
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+			return
+		}
+
+		if !order.IsOpen() {
+			c.JSON(http.StatusConflict, gin.H{"error": "order is " + order.CurrentStatus()})
 			return
 		}
 
-		updateObj = append(updateObj, bson.E{"updated_at", time.Now()})
+		// a new table is a transfer, so it is recorded and occupancy follows
+		transferWholeOrder(ctx, c, order, tableMove(c, order, input.Table_id))
+	}
+}
+
+// TRANSITION ORDER STATUS
+func TransitionOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orderId := c.Param("order_id")
+
+		var input struct {
+			Status *string `json:"status" validate:"required"`
+			Reason string  `json:"reason" validate:"max=200"`
+		}
 
-		result, err := orderCollection.UpdateOne(
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var order models.Order
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+			return
+		}
+
+		from := order.CurrentStatus()
+		to := strings.ToUpper(*input.Status)
+
+		if to == models.OrderStatusCancelled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cancel orders through POST /orders/" + orderId + "/cancel with a reason"})
+			return
+		}
+
+		if !models.CanTransitionOrder(from, to) {
+			// cancelling has its own endpoint, so it is not offered here
+			allowed := []string{}
+			for _, status := range models.NextOrderStatuses(from) {
+				if status != models.OrderStatusCancelled {
+					allowed = append(allowed, status)
+				}
+			}
+
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "order cannot move from " + from + " to " + to,
+				"allowed": allowed,
+			})
+			return
+		}
+
+		order, err := transitionOrder(ctx, order, to, input.Reason, c.GetString("uid"))
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusConflict, gin.H{"error": "order status changed meanwhile, reload and try again"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "order transition failed"})
+			return
+		}
+
+		if !order.IsOpen() {
+			updateTableOccupancy(ctx, order.Table_id)
+		}
+
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// FIRE COURSE
+func FireCourse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orderId := c.Param("order_id")
+
+		course, err := strconv.Atoi(c.Query("course"))
+		if err != nil || course < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "course must be a positive number"})
+			return
+		}
+
+		var order models.Order
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+			return
+		}
+
+		switch order.CurrentStatus() {
+		case models.OrderStatusServed, models.OrderStatusClosed, models.OrderStatusCancelled:
+			c.JSON(http.StatusConflict, gin.H{"error": "order is " + order.CurrentStatus()})
+			return
+		}
+
+		filter := bson.M{
+			"order_id":    orderId,
+			"course":      course,
+			"held":        true,
+			"prep_status": bson.M{"$ne": models.PrepStatusCancelled},
+		}
+
+		cursor, err := orderItemCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var items []models.OrderItem
+		if err := cursor.All(ctx, &items); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if len(items) == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "course " + strconv.Itoa(course) + " has no held items"})
+			return
+		}
+
+		now := time.Now()
+		ids := bson.A{}
+		for _, item := range items {
+			ids = append(ids, item.Order_item_id)
+		}
+
+		_, err = orderItemCollection.UpdateMany(
 			ctx,
-			bson.M{"order_id": orderId},
-			bson.D{{"$set", updateObj}},
+			bson.M{"order_item_id": bson.M{"$in": ids}, "held": true},
+			bson.D{{"$set", bson.D{{"held", false}, {"fired_at", now}, {"updated_at", now}}}},
 		)
-
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "order update failed"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "course could not be fired"})
 			return
 		}
 
-		c.JSON(http.StatusOK, result)
+		for _, item := range items {
+			item.Held = false
+			item.Fired_at = &now
+			item.Updated_at = now
+			publishKitchenEvent(models.KitchenEventItemFired, item)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"order_id": orderId,
+			"course":   course,
+			"fired":    len(items),
+		})
+	}
+}
+
+// orderCourses groups an order's live items by course, in course order.
+func orderCourses(ctx context.Context, orderId string) ([]OrderCourse, error) {
+	cursor, err := orderItemCollection.Find(
+		ctx,
+		bson.M{"order_id": orderId, "prep_status": bson.M{"$ne": models.PrepStatusCancelled}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []models.OrderItem
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	byCourse := map[int]*OrderCourse{}
+	for _, item := range items {
+		number := item.CurrentCourse()
+		course, ok := byCourse[number]
+		if !ok {
+			course = &OrderCourse{Course: number}
+			byCourse[number] = course
+		}
+
+		course.Items++
+		if item.Held {
+			course.Held++
+		}
+		if item.CurrentPrepStatus() == models.PrepStatusReady {
+			course.Ready++
+		}
 	}
+
+	courses := []OrderCourse{}
+	for _, course := range byCourse {
+		switch {
+		case course.Held > 0:
+			course.Status = CourseStatusHeld
+		case course.Ready == course.Items:
+			course.Status = CourseStatusReady
+		default:
+			course.Status = CourseStatusFired
+		}
+		courses = append(courses, *course)
+	}
+
+	sort.Slice(courses, func(i, j int) bool { return courses[i].Course < courses[j].Course })
+
+	return courses, nil
+}
+
+// transitionOrder moves the order to the given status and records the change.
+// It matches on the status the order was read with, so of two concurrent
+// transitions only one applies; the other gets mongo.ErrNoDocuments.
+func transitionOrder(ctx context.Context, order models.Order, to, reason, actor string) (models.Order, error) {
+	now := time.Now()
+	change := models.OrderStatusChange{
+		From:       order.CurrentStatus(),
+		Status:     to,
+		Reason:     reason,
+		Changed_by: actor,
+		Changed_at: now,
+	}
+
+	var updated models.Order
+	err := orderCollection.FindOneAndUpdate(
+		ctx,
+		bson.M{"order_id": order.Order_id, "status": order.Status},
+		bson.D{
+			{"$set", bson.D{{"status", to}, {"updated_at", now}}},
+			{"$push", bson.D{{"status_history", change}}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+
+	return updated, err
+}
+
+// placeOrder starts a new order's lifecycle.
+func placeOrder(order *models.Order, actor string) {
+	status := models.OrderStatusPlaced
+	order.Status = &status
+	order.Status_history = []models.OrderStatusChange{{
+		Status:     status,
+		Changed_by: actor,
+		Changed_at: order.Created_at,
+	}}
 }
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCreateOrderIgnoresServerFields(t *testing.T) {
+	useTestDatabase(t)
+	insertAll(t, tableCollection, bson.M{"table_id": "table-1", "table_number": 7})
+
+	body := gin.H{
+		"table_id":    "table-1",
+		"customer_id": "555-0100",
+		"status":      models.OrderStatusClosed,
+		"merged_into": "order-elsewhere",
+		"moves":       []gin.H{{"kind": models.OrderMoveMerge, "to_order_id": "order-elsewhere"}},
+	}
+
+	rec := serve(CreateOrder(), http.MethodPost, "/orders", "/orders", body, waiter)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+
+	var order models.Order
+	if err := orderCollection.FindOne(context.Background(), bson.M{"table_id": "table-1"}).Decode(&order); err != nil {
+		t.Fatal(err)
+	}
+
+	if order.CurrentStatus() != models.OrderStatusPlaced {
+		t.Errorf("got status %s, want PLACED", order.CurrentStatus())
+	}
+	if order.Merged_into != nil {
+		t.Errorf("client set merged_into to %s", *order.Merged_into)
+	}
+	if len(order.Moves) != 0 {
+		t.Errorf("client set moves: %+v", order.Moves)
+	}
+	if order.Customer_id == nil || *order.Customer_id != "555-0100" {
+		t.Errorf("got customer %v, want 555-0100", order.Customer_id)
+	}
+}
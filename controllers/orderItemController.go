@@ -3,9 +3,12 @@ package controllers
 import (
 	"context"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"restaurant-management/database"
+	helper "restaurant-management/helpers"
 	"restaurant-management/models"
 
 	"github.com/gin-gonic/gin"
@@ -19,8 +22,7 @@ type OrderItemPack struct {
 	Order_items []models.OrderItem `json:"order_items" validate:"required,dive"`
 }
 
-var orderItemCollection *mongo.Collection =
-	database.OpenCollection(database.Client, "order_items")
+var orderItemCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionOrderItems)
 
 // GET ALL ORDER ITEMS
 func GetOrderItems() gin.HandlerFunc {
@@ -108,16 +110,77 @@ func UpdateOrderItem() gin.HandlerFunc {
 			return
 		}
 
+		var current models.OrderItem
+		if err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&current); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order item not found"})
+			return
+		}
+
+		if current.Void != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "a voided order item cannot be changed"})
+			return
+		}
+
+		if !itemsChangeable(ctx, c, current.Order_id) {
+			return
+		}
+
+		// taking back what the kitchen may already be cooking is a void,
+		// which needs a reason and, for staff, a manager's approval
+		if current.SentToKitchen() {
+			reduced := input.Quantity != nil && *input.Quantity < current.CurrentQuantity()
+			replaced := (input.Food_id != nil && (current.Food_id == nil || *input.Food_id != *current.Food_id)) ||
+				(input.Size != nil && (current.Size == nil || *input.Size != *current.Size)) ||
+				input.Modifiers != nil
+			if reduced || replaced {
+				c.JSON(http.StatusConflict, gin.H{"error": "the kitchen already has this item; void it, or part of its quantity, through POST /orderItems/" + orderItemId + "/void"})
+				return
+			}
+		}
+
+		if input.Food_id != nil && !validateReferences(ctx, c, foodRef("food_id", input.Food_id)) {
+			return
+		}
+
 		update := bson.D{}
 
 		if input.Quantity != nil {
+			if *input.Quantity < 1 || *input.Quantity > 99 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be between 1 and 99"})
+				return
+			}
 			update = append(update, bson.E{"quantity", input.Quantity})
 		}
-		if input.Unit_price != nil {
-			update = append(update, bson.E{"unit_price", input.Unit_price})
-		}
-		if input.Food_id != nil {
-			update = append(update, bson.E{"food_id", input.Food_id})
+
+		// a new food, size or modifiers, or a price override, means a new
+		// price snapshot
+		if input.Food_id != nil || input.Size != nil || input.Modifiers != nil || input.Unit_price != nil {
+			priced := []models.OrderItem{current}
+			if input.Food_id != nil {
+				priced[0].Food_id = input.Food_id
+			}
+			if input.Size != nil {
+				priced[0].Size = input.Size
+			}
+			if input.Modifiers != nil {
+				priced[0].Modifiers = input.Modifiers
+			}
+			priced[0].Unit_price = input.Unit_price
+
+			if err := priceOrderItems(ctx, c, priced); err != nil {
+				abortPricing(c, err)
+				return
+			}
+
+			update = append(update,
+				bson.E{"food_id", priced[0].Food_id},
+				bson.E{"food_name", priced[0].Food_name},
+				bson.E{"size", priced[0].Size},
+				bson.E{"modifiers", priced[0].Modifiers},
+				bson.E{"list_price", priced[0].List_price},
+				bson.E{"unit_price", priced[0].Unit_price},
+				bson.E{"price_override_by", priced[0].Price_override_by},
+			)
 		}
 
 		if len(update) == 0 {
@@ -138,6 +201,11 @@ func UpdateOrderItem() gin.HandlerFunc {
 			return
 		}
 
+		var item models.OrderItem
+		if err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&item); err == nil && !item.Held {
+			publishKitchenEvent(models.KitchenEventItemUpdated, item)
+		}
+
 		c.JSON(http.StatusOK, result)
 	}
 }
@@ -154,11 +222,45 @@ func CreateOrderItem() gin.HandlerFunc {
 			return
 		}
 
+		if pack.Table_id == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "table_id is required"})
+			return
+		}
+
 		if len(pack.Order_items) == 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "order_items cannot be empty"})
 			return
 		}
 
+		refs := []reference{tableRef("table_id", pack.Table_id)}
+		for i, item := range pack.Order_items {
+			refs = append(refs, foodRef("order_items["+strconv.Itoa(i)+"].food_id", item.Food_id))
+		}
+		if !validateReferences(ctx, c, refs...) {
+			return
+		}
+
+		for _, item := range pack.Order_items {
+			if item.Quantity == nil || *item.Quantity < 1 || *item.Quantity > 99 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be between 1 and 99"})
+				return
+			}
+			if item.Course != nil && (*item.Course < 1 || *item.Course > 9) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "course must be between 1 and 9"})
+				return
+			}
+		}
+
+		if err := priceOrderItems(ctx, c, pack.Order_items); err != nil {
+			abortPricing(c, err)
+			return
+		}
+
+		if err := routeOrderItems(ctx, pack.Order_items); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not route order items to stations"})
+			return
+		}
+
 		order := models.Order{
 			ID:         primitive.NewObjectID(),
 			Order_id:   primitive.NewObjectID().Hex(),
@@ -166,70 +268,259 @@ func CreateOrderItem() gin.HandlerFunc {
 			Created_at: time.Now(),
 			Updated_at: time.Now(),
 		}
-
-		_, err := orderCollection.InsertOne(ctx, order)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "order creation failed"})
-			return
-		}
+		placeOrder(&order, c.GetString("uid"))
 
 		var docs []interface{}
-		for _, item := range pack.Order_items {
+		for i := range pack.Order_items {
+			item := &pack.Order_items[i]
+			prepStatus := models.PrepStatusQueued
 			item.ID = primitive.NewObjectID()
 			item.Order_item_id = item.ID.Hex()
 			item.Order_id = order.Order_id
+			item.Prep_status = &prepStatus
+			item.Bumped_at = nil
 			item.Created_at = time.Now()
 			item.Updated_at = time.Now()
-			docs = append(docs, item)
+			item.Fired_at = nil
+			// items are only voided through the void flow, never on arrival
+			item.Void = nil
+			if item.Course == nil {
+				course := 1
+				item.Course = &course
+			}
+			if !item.Held {
+				firedAt := item.Created_at
+				item.Fired_at = &firedAt
+			}
+			docs = append(docs, *item)
 		}
 
-		result, err := orderItemCollection.InsertMany(ctx, docs)
+		// the order and its items are written together or not at all, so a
+		// failed item insert cannot leave an empty order behind
+		var result *mongo.InsertManyResult
+		err := helper.RunAtomic(
+			ctx,
+			func(ctx context.Context) error {
+				if _, err := orderCollection.InsertOne(ctx, order); err != nil {
+					return err
+				}
+
+				var err error
+				result, err = orderItemCollection.InsertMany(ctx, docs)
+				return err
+			},
+			func(ctx context.Context) error {
+				if _, err := orderItemCollection.DeleteMany(ctx, bson.M{"order_id": order.Order_id}); err != nil {
+					return err
+				}
+				_, err := orderCollection.DeleteOne(ctx, bson.M{"order_id": order.Order_id})
+				return err
+			},
+		)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to insert order items"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "order creation failed"})
 			return
 		}
 
+		updateTableOccupancy(ctx, order.Table_id)
+
+		// held items reach the kitchen when their course is fired
+		for _, item := range pack.Order_items {
+			if !item.Held {
+				publishKitchenEvent(models.KitchenEventItemCreated, item)
+			}
+		}
+
 		c.JSON(http.StatusCreated, gin.H{
-			"order_id":     order.Order_id,
-			"order_items":  result,
+			"order_id":    order.Order_id,
+			"order_items": result,
 		})
 	}
 }
 
+// pricingError is a pricing problem the client has to fix.
+type pricingError struct {
+	status  int
+	message string
+}
 
-func ItemsByOrder(orderID string) ([]bson.M, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func (e pricingError) Error() string { return e.message }
 
-	matchStage := bson.D{{"$match", bson.D{{"order_id", orderID}}}}
+// priceOrderItems snapshots the food's name and its current price for the
+// ordered size onto each item, so later menu changes leave the bill alone. A
+// price sent by the client is only kept when a manager places or edits the
+// item, and the override is recorded.
+func priceOrderItems(ctx context.Context, c *gin.Context, items []models.OrderItem) error {
+	role := c.GetString("role")
+	canOverride := role == models.RoleAdmin || role == models.RoleManager
 
-	lookupFoodStage := bson.D{{"$lookup", bson.D{
-		{"from", "food"},
-		{"localField", "food_id"},
-		{"foreignField", "food_id"},
-		{"as", "food"},
-	}}}
+	foodIds := bson.A{}
+	for _, item := range items {
+		if item.Food_id == nil {
+			return pricingError{http.StatusBadRequest, "food_id is required"}
+		}
+		if item.Unit_price != nil && !canOverride {
+			return pricingError{http.StatusForbidden, "unit_price is set from the menu, only a manager can override it"}
+		}
+		foodIds = append(foodIds, *item.Food_id)
+	}
 
-	unwindFoodStage := bson.D{{"$unwind", bson.D{
-		{"path", "$food"},
-		{"preserveNullAndEmptyArrays", true},
-	}}}
+	cursor, err := foodCollection.Find(ctx, bson.M{"food_id": bson.M{"$in": foodIds}})
+	if err != nil {
+		return err
+	}
 
-	lookupOrderStage := bson.D{{"$lookup", bson.D{
-		{"from", "order"},
-		{"localField", "order_id"},
-		{"foreignField", "order_id"},
-		{"as", "order"},
-	}}}
+	var foods []models.Food
+	if err := cursor.All(ctx, &foods); err != nil {
+		return err
+	}
 
-	unwindOrderStage := bson.D{{"$unwind", bson.D{
-		{"path", "$order"},
-		{"preserveNullAndEmptyArrays", true},
-	}}}
+	byId := map[string]models.Food{}
+	for _, food := range foods {
+		byId[food.Food_id] = food
+	}
+
+	for i := range items {
+		item := &items[i]
+
+		food, ok := byId[*item.Food_id]
+		if !ok {
+			return pricingError{http.StatusBadRequest, "food " + *item.Food_id + " not found"}
+		}
+
+		size := ""
+		if item.Size != nil {
+			size = strings.ToUpper(strings.TrimSpace(*item.Size))
+			item.Size = &size
+		}
+
+		listPrice, ok := food.SizePrice(size)
+		if !ok {
+			if size == "" {
+				return pricingError{http.StatusBadRequest, "choose a size for " + *food.Name}
+			}
+			return pricingError{http.StatusBadRequest, *food.Name + " has no size " + size}
+		}
+
+		modifiers, err := selectModifiers(food, item.Modifiers)
+		if err != nil {
+			return err
+		}
+		item.Modifiers = modifiers
+		for _, modifier := range modifiers {
+			listPrice += modifier.Price_delta
+		}
+		listPrice = toFixed(listPrice, 2)
+		item.Food_name = food.Name
+		item.List_price = &listPrice
+		item.Price_override_by = ""
+
+		if item.Unit_price != nil {
+			price := toFixed(*item.Unit_price, 2)
+			item.Unit_price = &price
+			item.Price_override_by = c.GetString("uid")
+		} else {
+			item.Unit_price = &listPrice
+		}
+	}
+
+	return nil
+}
+
+// selectModifiers resolves the options picked for a food against its modifier
+// groups and enforces each group's selection rules.
+func selectModifiers(food models.Food, picked []models.SelectedModifier) ([]models.SelectedModifier, error) {
+	type choice struct {
+		group  models.ModifierGroup
+		option models.ModifierOption
+	}
+
+	options := map[string]choice{}
+	for _, group := range food.Modifier_groups {
+		for _, option := range group.Options {
+			options[option.Option_id] = choice{group, option}
+		}
+	}
+
+	selected := []models.SelectedModifier{}
+	perGroup := map[string]int{}
+	seen := map[string]bool{}
+
+	for _, pick := range picked {
+		found, ok := options[pick.Option_id]
+		if !ok || (pick.Group_id != "" && pick.Group_id != found.group.Group_id) {
+			return nil, pricingError{http.StatusBadRequest, *food.Name + " has no modifier option " + pick.Option_id}
+		}
+		if seen[pick.Option_id] {
+			return nil, pricingError{http.StatusBadRequest, found.option.Name + " is selected twice"}
+		}
+		seen[pick.Option_id] = true
+		perGroup[found.group.Group_id]++
+
+		selected = append(selected, models.SelectedModifier{
+			Group_id:    found.group.Group_id,
+			Group_name:  found.group.Name,
+			Option_id:   found.option.Option_id,
+			Option_name: found.option.Name,
+			Price_delta: found.option.Price_delta,
+		})
+	}
+
+	for _, group := range food.Modifier_groups {
+		count := perGroup[group.Group_id]
+		if count < group.Min_select {
+			return nil, pricingError{http.StatusBadRequest, *food.Name + ": choose at least " + strconv.Itoa(group.Min_select) + " of " + group.Name}
+		}
+		if group.Max_select > 0 && count > group.Max_select {
+			return nil, pricingError{http.StatusBadRequest, *food.Name + ": choose at most " + strconv.Itoa(group.Max_select) + " of " + group.Name}
+		}
+	}
+
+	return selected, nil
+}
+
+func abortPricing(c *gin.Context, err error) {
+	if e, ok := err.(pricingError); ok {
+		c.JSON(e.status, gin.H{"error": e.message})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "could not price order items"})
+}
+
+// InvoiceDetail is an order's bill as computed by ItemsByOrder.
+type InvoiceDetail struct {
+	Order_id     string        `json:"order_id"`
+	Table_id     *string       `json:"table_id"`
+	Table_number *int          `json:"table_number"`
+	Payment_due  float64       `json:"payment_due"`
+	Total_count  int           `json:"total_count"`
+	Order_items  []InvoiceLine `json:"order_items"`
+}
+
+// InvoiceLine is one order item on a bill. Amount is price × quantity.
+type InvoiceLine struct {
+	Order_item_id string                    `json:"order_item_id"`
+	Food_id       *string                   `json:"food_id"`
+	Food_name     *string                   `json:"food_name"`
+	Food_image    *string                   `json:"food_image"`
+	Size          *string                   `json:"size"`
+	Modifiers     []models.SelectedModifier `json:"modifiers"`
+	Price         float64                   `json:"price"`
+	Quantity      int                       `json:"quantity"`
+	Amount        float64                   `json:"amount"`
+}
+
+// ItemsByOrder computes the bill of an order. An order without items has an
+// empty bill; mongo.ErrNoDocuments is returned when the order does not exist.
+func ItemsByOrder(orderID string) (InvoiceDetail, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	matchStage := bson.D{{"$match", bson.D{{"order_id", orderID}}}}
 
 	lookupTableStage := bson.D{{"$lookup", bson.D{
-		{"from", "table"},
-		{"localField", "order.table_id"},
+		{"from", database.CollectionTables},
+		{"localField", "table_id"},
 		{"foreignField", "table_id"},
 		{"as", "table"},
 	}}}
@@ -239,57 +530,75 @@ func ItemsByOrder(orderID string) ([]bson.M, error) {
 		{"preserveNullAndEmptyArrays", true},
 	}}}
 
-	// calculate amount = price * quantity
-	projectStage := bson.D{{"$project", bson.D{
-		{"food_name", "$food.name"},
-		{"food_image", "$food.food_image"},
-		{"table_number", "$table.table_number"},
-		{"table_id", "$table.table_id"},
-		{"order_id", "$order.order_id"},
-		{"price", "$food.price"},
-		{"quantity", 1},
-		{"amount", bson.D{{"$multiply", bson.A{"$food.price", "$quantity"}}}},
-	}}}
-
-	groupStage := bson.D{{"$group", bson.D{
-		{"_id", bson.D{
-			{"order_id", "$order_id"},
-			{"table_id", "$table_id"},
-			{"table_number", "$table_number"},
+	// bill the price snapshot taken when the item was ordered; only items
+	// from before snapshots fall back to the current menu price
+	price := bson.D{{"$ifNull", bson.A{"$unit_price", "$food.price", 0}}}
+	quantity := bson.D{{"$ifNull", bson.A{"$quantity", 1}}}
+
+	lookupItemsStage := bson.D{{"$lookup", bson.D{
+		{"from", database.CollectionOrderItems},
+		{"let", bson.D{{"order_id", "$order_id"}}},
+		{"pipeline", mongo.Pipeline{
+			{{"$match", bson.D{{"$expr", bson.D{{"$eq", bson.A{"$order_id", "$$order_id"}}}}}}},
+			// voided items are not billed
+			{{"$match", bson.D{{"void", nil}}}},
+			{{"$sort", bson.D{{"created_at", 1}}}},
+			{{"$lookup", bson.D{
+				{"from", database.CollectionFoods},
+				{"localField", "food_id"},
+				{"foreignField", "food_id"},
+				{"as", "food"},
+			}}},
+			{{"$unwind", bson.D{
+				{"path", "$food"},
+				{"preserveNullAndEmptyArrays", true},
+			}}},
+			// calculate amount = price * quantity
+			{{"$project", bson.D{
+				{"_id", 0},
+				{"order_item_id", 1},
+				{"food_id", 1},
+				{"food_name", bson.D{{"$ifNull", bson.A{"$food_name", "$food.name"}}}},
+				{"food_image", "$food.food_image"},
+				{"size", 1},
+				{"modifiers", 1},
+				{"price", price},
+				{"quantity", quantity},
+				{"amount", bson.D{{"$round", bson.A{bson.D{{"$multiply", bson.A{price, quantity}}}, 2}}}},
+			}}},
 		}},
-		{"payment_due", bson.D{{"$sum", "$amount"}}},
-		{"total_count", bson.D{{"$sum", "$quantity"}}},
-		{"order_items", bson.D{{"$push", "$$ROOT"}}},
+		{"as", "order_items"},
 	}}}
 
-	finalProject := bson.D{{"$project", bson.D{
+	projectStage := bson.D{{"$project", bson.D{
 		{"_id", 0},
-		{"payment_due", 1},
-		{"total_count", 1},
-		{"table_number", "$_id.table_number"},
+		{"order_id", 1},
+		{"table_id", 1},
+		{"table_number", "$table.table_number"},
 		{"order_items", 1},
+		{"payment_due", bson.D{{"$round", bson.A{bson.D{{"$sum", "$order_items.amount"}}, 2}}}},
+		{"total_count", bson.D{{"$sum", "$order_items.quantity"}}},
 	}}}
 
-	cursor, err := orderItemCollection.Aggregate(ctx, mongo.Pipeline{
+	cursor, err := orderCollection.Aggregate(ctx, mongo.Pipeline{
 		matchStage,
-		lookupFoodStage,
-		unwindFoodStage,
-		lookupOrderStage,
-		unwindOrderStage,
 		lookupTableStage,
 		unwindTableStage,
+		lookupItemsStage,
 		projectStage,
-		groupStage,
-		finalProject,
 	})
 	if err != nil {
-		return nil, err
+		return InvoiceDetail{}, err
 	}
 
-	var results []bson.M
+	var results []InvoiceDetail
 	if err = cursor.All(ctx, &results); err != nil {
-		return nil, err
+		return InvoiceDetail{}, err
+	}
+
+	if len(results) == 0 {
+		return InvoiceDetail{}, mongo.ErrNoDocuments
 	}
 
-	return results, nil
+	return results[0], nil
 }
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestItemsByOrder(t *testing.T) {
+	useTestDatabase(t)
+
+	insertAll(t, tableCollection, bson.M{"table_id": "table-1", "table_number": 7})
+	insertAll(t, foodCollection, bson.M{"food_id": "food-soup", "name": "Soup", "price": 4.25})
+	insertAll(t, orderCollection,
+		bson.M{"order_id": "order-empty", "table_id": "table-1"},
+		bson.M{"order_id": "order-full", "table_id": "table-1"},
+	)
+	insertAll(t, orderItemCollection,
+		bson.M{"order_item_id": "steak", "order_id": "order-full", "food_id": "food-steak", "food_name": "Steak", "unit_price": 12.5, "quantity": 2, "created_at": time.Now()},
+		// from before price snapshots: billed at the current menu price, once
+		bson.M{"order_item_id": "soup", "order_id": "order-full", "food_id": "food-soup", "created_at": time.Now().Add(time.Second)},
+		bson.M{"order_item_id": "wine", "order_id": "order-full", "food_name": "Wine", "unit_price": 100.0, "quantity": 1, "void": bson.M{"reason": "GUEST_REQUEST"}},
+	)
+
+	t.Run("missing order", func(t *testing.T) {
+		if _, err := ItemsByOrder("order-missing"); err != mongo.ErrNoDocuments {
+			t.Fatalf("got %v, want mongo.ErrNoDocuments", err)
+		}
+	})
+
+	t.Run("empty order", func(t *testing.T) {
+		detail, err := ItemsByOrder("order-empty")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if detail.Payment_due != 0 || detail.Total_count != 0 || len(detail.Order_items) != 0 {
+			t.Errorf("got %+v, want an empty bill", detail)
+		}
+		if detail.Table_number == nil || *detail.Table_number != 7 {
+			t.Errorf("got table number %v, want 7", detail.Table_number)
+		}
+	})
+
+	t.Run("priced items without voids", func(t *testing.T) {
+		detail, err := ItemsByOrder("order-full")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if detail.Table_number == nil || *detail.Table_number != 7 {
+			t.Errorf("got table number %v, want 7", detail.Table_number)
+		}
+		if detail.Payment_due != 29.25 {
+			t.Errorf("got payment due %v, want 29.25", detail.Payment_due)
+		}
+		if detail.Total_count != 3 {
+			t.Errorf("got %d items, want 3", detail.Total_count)
+		}
+
+		if len(detail.Order_items) != 2 {
+			t.Fatalf("got %d lines, want 2 without the voided one", len(detail.Order_items))
+		}
+
+		steak, soup := detail.Order_items[0], detail.Order_items[1]
+		if steak.Order_item_id != "steak" || steak.Price != 12.5 || steak.Quantity != 2 || steak.Amount != 25 {
+			t.Errorf("steak line is %+v", steak)
+		}
+		if soup.Order_item_id != "soup" || soup.Price != 4.25 || soup.Quantity != 1 || soup.Amount != 4.25 {
+			t.Errorf("soup line is %+v", soup)
+		}
+		if soup.Food_name == nil || *soup.Food_name != "Soup" {
+			t.Errorf("soup line should fall back to the food's name, got %v", soup.Food_name)
+		}
+	})
+}
+
+func TestCreateOrderItemBillsPreVoidedItems(t *testing.T) {
+	useTestDatabase(t)
+
+	insertAll(t, tableCollection, bson.M{"table_id": "table-1", "table_number": 7})
+	insertAll(t, foodCollection, bson.M{"food_id": "food-steak", "name": "Steak", "price": 12.5})
+
+	// held, so nothing is published to the kitchen
+	body := gin.H{
+		"table_id": "table-1",
+		"order_items": []gin.H{{
+			"food_id":  "food-steak",
+			"quantity": 2,
+			"held":     true,
+			"void":     gin.H{"reason": "GUEST_REQUEST", "voided_by": "waiter-1"},
+		}},
+	}
+
+	rec := serve(CreateOrderItem(), http.MethodPost, "/orderItems", "/orderItems", body, waiter)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+
+	var item models.OrderItem
+	if err := orderItemCollection.FindOne(context.Background(), bson.M{"food_id": "food-steak"}).Decode(&item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Void != nil {
+		t.Errorf("client stored a void on a new item: %+v", item.Void)
+	}
+
+	detail, err := ItemsByOrder(item.Order_id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detail.Payment_due != 25 || len(detail.Order_items) != 1 {
+		t.Errorf("got payment due %v over %d lines, want 25 over 1", detail.Payment_due, len(detail.Order_items))
+	}
+}
@@ -0,0 +1,532 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	helper "restaurant-management/helpers"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// errOrderChanged stops a move when one of its orders changed status or
+// items between reading and writing it.
+var errOrderChanged = errors.New("order changed meanwhile, reload and try again")
+
+// TRANSFER ORDER
+func TransferOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orderId := c.Param("order_id")
+
+		var input struct {
+			Table_id       *string  `json:"table_id" validate:"required"`
+			Order_item_ids []string `json:"order_item_ids"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var order models.Order
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+			return
+		}
+
+		if !order.IsOpen() {
+			c.JSON(http.StatusConflict, gin.H{"error": "order is " + order.CurrentStatus()})
+			return
+		}
+
+		if !validateReferences(ctx, c, tableRef("table_id", input.Table_id)) {
+			return
+		}
+
+		move := tableMove(c, order, input.Table_id)
+
+		itemIds := uniqueStrings(input.Order_item_ids)
+		if len(itemIds) == 0 {
+			transferWholeOrder(ctx, c, order, move)
+			return
+		}
+
+		// a served order may already be billed, so its items stay together
+		if order.CurrentStatus() == models.OrderStatusServed {
+			c.JSON(http.StatusConflict, gin.H{"error": "a served order can only be transferred as a whole"})
+			return
+		}
+
+		cursor, err := orderItemCollection.Find(ctx, bson.M{"order_id": orderId, "void": nil})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var live []models.OrderItem
+		if err := cursor.All(ctx, &live); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		selected := map[string]bool{}
+		for _, id := range itemIds {
+			selected[id] = true
+		}
+
+		var moving []models.OrderItem
+		for _, item := range live {
+			if selected[item.Order_item_id] {
+				moving = append(moving, item)
+			}
+		}
+
+		if len(moving) != len(itemIds) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "every order item has to be on this order and not voided"})
+			return
+		}
+
+		if len(moving) == len(live) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to move every item, transfer the whole order"})
+			return
+		}
+
+		now := move.Moved_at
+		target := models.Order{
+			ID:         primitive.NewObjectID(),
+			Order_Date: now,
+			Table_id:   input.Table_id,
+			Status:     order.Status,
+			Created_at: now,
+			Updated_at: now,
+		}
+		target.Order_id = target.ID.Hex()
+
+		// the new order picks up where the items' old order is
+		target.Status_history = []models.OrderStatusChange{{
+			Status:     order.CurrentStatus(),
+			Reason:     "split from order " + order.Order_id,
+			Changed_by: move.Moved_by,
+			Changed_at: now,
+		}}
+
+		move.Kind = models.OrderMoveSplit
+		move.To_order_id = target.Order_id
+		move.Order_item_ids = itemIds
+		target.Moves = []models.OrderMove{move}
+
+		ids := bson.A{}
+		for _, id := range itemIds {
+			ids = append(ids, id)
+		}
+
+		err = helper.RunAtomic(
+			ctx,
+			func(ctx context.Context) error {
+				if _, err := orderCollection.InsertOne(ctx, target); err != nil {
+					return err
+				}
+
+				result, err := orderItemCollection.UpdateMany(
+					ctx,
+					bson.M{"order_item_id": bson.M{"$in": ids}, "order_id": order.Order_id, "void": nil},
+					bson.D{{"$set", bson.D{{"order_id", target.Order_id}, {"updated_at", now}}}},
+				)
+				if err != nil {
+					return err
+				}
+				if result.ModifiedCount != int64(len(ids)) {
+					return errOrderChanged
+				}
+
+				return recordOrderMove(ctx, order, move)
+			},
+			func(ctx context.Context) error {
+				_, err := orderItemCollection.UpdateMany(
+					ctx,
+					bson.M{"order_item_id": bson.M{"$in": ids}, "order_id": target.Order_id},
+					bson.D{{"$set", bson.D{{"order_id", order.Order_id}}}},
+				)
+				if err != nil {
+					return err
+				}
+				if _, err := orderCollection.DeleteOne(ctx, bson.M{"order_id": target.Order_id}); err != nil {
+					return err
+				}
+				return forgetOrderMove(ctx, order.Order_id, move)
+			},
+		)
+		if err == errOrderChanged {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "order items could not be transferred"})
+			return
+		}
+
+		updateTableOccupancy(ctx, order.Table_id, target.Table_id)
+
+		for i := range moving {
+			moving[i].Order_id = target.Order_id
+		}
+		publishMovedItems(moving)
+
+		c.JSON(http.StatusCreated, target)
+	}
+}
+
+// MERGE ORDERS
+func MergeOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var input struct {
+			Into_order_id *string  `json:"into_order_id" validate:"required"`
+			Order_ids     []string `json:"order_ids" validate:"required,min=1"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		orderIds := uniqueStrings(input.Order_ids)
+		if len(orderIds) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "order_ids must name at least one order"})
+			return
+		}
+		for _, id := range orderIds {
+			if id == *input.Into_order_id {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "an order cannot be merged into itself"})
+				return
+			}
+		}
+
+		var target models.Order
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": input.Into_order_id}).Decode(&target); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order " + *input.Into_order_id + " not found"})
+			return
+		}
+
+		cursor, err := orderCollection.Find(ctx, bson.M{"order_id": bson.M{"$in": orderIds}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var sources []models.Order
+		if err := cursor.All(ctx, &sources); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if len(sources) != len(orderIds) {
+			found := map[string]bool{}
+			for _, source := range sources {
+				found[source.Order_id] = true
+			}
+			var missing []string
+			for _, id := range orderIds {
+				if !found[id] {
+					missing = append(missing, id)
+				}
+			}
+			c.JSON(http.StatusNotFound, gin.H{"error": "order " + strings.Join(missing, ", ") + " not found"})
+			return
+		}
+
+		// served orders may already be billed
+		for _, order := range append([]models.Order{target}, sources...) {
+			if !order.IsOpen() || order.CurrentStatus() == models.OrderStatusServed {
+				c.JSON(http.StatusConflict, gin.H{"error": "order " + order.Order_id + " is " + order.CurrentStatus() + " and cannot be merged"})
+				return
+			}
+		}
+
+		now := time.Now()
+		actor := c.GetString("uid")
+
+		moved := map[string][]models.OrderItem{}
+		var moves []models.OrderMove
+		for _, source := range sources {
+			cursor, err := orderItemCollection.Find(ctx, bson.M{"order_id": source.Order_id})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			var items []models.OrderItem
+			if err := cursor.All(ctx, &items); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			moved[source.Order_id] = items
+
+			itemIds := []string{}
+			for _, item := range items {
+				itemIds = append(itemIds, item.Order_item_id)
+			}
+
+			moves = append(moves, models.OrderMove{
+				Kind:           models.OrderMoveMerge,
+				From_order_id:  source.Order_id,
+				To_order_id:    target.Order_id,
+				From_table_id:  source.Table_id,
+				To_table_id:    target.Table_id,
+				Order_item_ids: itemIds,
+				Moved_by:       actor,
+				Moved_at:       now,
+			})
+		}
+
+		err = helper.RunAtomic(
+			ctx,
+			func(ctx context.Context) error {
+				for i, source := range sources {
+					_, err := orderItemCollection.UpdateMany(
+						ctx,
+						bson.M{"order_id": source.Order_id},
+						bson.D{{"$set", bson.D{{"order_id", target.Order_id}, {"updated_at", now}}}},
+					)
+					if err != nil {
+						return err
+					}
+
+					change := models.OrderStatusChange{
+						From:       source.CurrentStatus(),
+						Status:     models.OrderStatusMerged,
+						Reason:     "merged into order " + target.Order_id,
+						Changed_by: actor,
+						Changed_at: now,
+					}
+
+					result, err := orderCollection.UpdateOne(
+						ctx,
+						bson.M{"order_id": source.Order_id, "status": source.Status},
+						bson.D{
+							{"$set", bson.D{
+								{"status", models.OrderStatusMerged},
+								{"merged_into", target.Order_id},
+								{"updated_at", now},
+							}},
+							{"$push", bson.D{
+								{"status_history", change},
+								{"moves", moves[i]},
+							}},
+						},
+					)
+					if err != nil {
+						return err
+					}
+					if result.MatchedCount == 0 {
+						return errOrderChanged
+					}
+				}
+
+				result, err := orderCollection.UpdateOne(
+					ctx,
+					bson.M{"order_id": target.Order_id, "status": target.Status},
+					bson.D{
+						{"$set", bson.D{{"updated_at", now}}},
+						{"$push", bson.D{{"moves", bson.D{{"$each", moves}}}}},
+					},
+				)
+				if err != nil {
+					return err
+				}
+				if result.MatchedCount == 0 {
+					return errOrderChanged
+				}
+				return nil
+			},
+			func(ctx context.Context) error {
+				for i, source := range sources {
+					ids := bson.A{}
+					for _, id := range moves[i].Order_item_ids {
+						ids = append(ids, id)
+					}
+
+					_, err := orderItemCollection.UpdateMany(
+						ctx,
+						bson.M{"order_item_id": bson.M{"$in": ids}},
+						bson.D{{"$set", bson.D{{"order_id", source.Order_id}}}},
+					)
+					if err != nil {
+						return err
+					}
+
+					_, err = orderCollection.UpdateOne(
+						ctx,
+						bson.M{"order_id": source.Order_id, "status": models.OrderStatusMerged, "merged_into": target.Order_id},
+						bson.D{
+							{"$set", bson.D{{"status", source.Status}, {"merged_into", nil}}},
+							{"$pull", bson.D{
+								{"status_history", bson.D{{"status", models.OrderStatusMerged}, {"changed_at", now}}},
+								{"moves", bson.D{{"kind", models.OrderMoveMerge}, {"moved_at", now}}},
+							}},
+						},
+					)
+					if err != nil {
+						return err
+					}
+				}
+
+				return forgetOrderMove(ctx, target.Order_id, moves[0])
+			},
+		)
+		if err == errOrderChanged {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "orders could not be merged"})
+			return
+		}
+
+		tableIds := []*string{target.Table_id}
+		for _, source := range sources {
+			tableIds = append(tableIds, source.Table_id)
+		}
+		updateTableOccupancy(ctx, tableIds...)
+
+		for _, items := range moved {
+			for i := range items {
+				items[i].Order_id = target.Order_id
+			}
+			publishMovedItems(items)
+		}
+
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": target.Order_id}).Decode(&target); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, target)
+	}
+}
+
+// tableMove describes the whole order moving to another table.
+func tableMove(c *gin.Context, order models.Order, tableId *string) models.OrderMove {
+	return models.OrderMove{
+		Kind:          models.OrderMoveTransfer,
+		From_order_id: order.Order_id,
+		To_order_id:   order.Order_id,
+		From_table_id: order.Table_id,
+		To_table_id:   tableId,
+		Moved_by:      c.GetString("uid"),
+		Moved_at:      time.Now(),
+	}
+}
+
+// transferWholeOrder moves the order itself; its items follow it.
+func transferWholeOrder(ctx context.Context, c *gin.Context, order models.Order, move models.OrderMove) {
+	if order.Table_id != nil && *order.Table_id == *move.To_table_id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order is already at that table"})
+		return
+	}
+
+	var updated models.Order
+	err := orderCollection.FindOneAndUpdate(
+		ctx,
+		bson.M{"order_id": order.Order_id, "table_id": order.Table_id, "status": order.Status},
+		bson.D{
+			{"$set", bson.D{{"table_id", move.To_table_id}, {"updated_at", move.Moved_at}}},
+			{"$push", bson.D{{"moves", move}}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusConflict, gin.H{"error": errOrderChanged.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "order could not be transferred"})
+		return
+	}
+
+	updateTableOccupancy(ctx, order.Table_id, move.To_table_id)
+
+	// the tickets on the kitchen displays show the table
+	cursor, err := orderItemCollection.Find(ctx, bson.M{"order_id": order.Order_id, "void": nil})
+	if err == nil {
+		var items []models.OrderItem
+		if cursor.All(ctx, &items) == nil {
+			publishMovedItems(items)
+		}
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func recordOrderMove(ctx context.Context, order models.Order, move models.OrderMove) error {
+	result, err := orderCollection.UpdateOne(
+		ctx,
+		bson.M{"order_id": order.Order_id, "status": order.Status},
+		bson.D{
+			{"$set", bson.D{{"updated_at", move.Moved_at}}},
+			{"$push", bson.D{{"moves", move}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errOrderChanged
+	}
+	return nil
+}
+
+func forgetOrderMove(ctx context.Context, orderId string, move models.OrderMove) error {
+	_, err := orderCollection.UpdateOne(
+		ctx,
+		bson.M{"order_id": orderId},
+		bson.D{{"$pull", bson.D{{"moves", bson.D{{"kind", move.Kind}, {"moved_at", move.Moved_at}}}}}},
+	)
+	return err
+}
+
+// publishMovedItems tells the kitchen displays about items that are already
+// being worked on; held and cancelled items are not on them.
+func publishMovedItems(items []models.OrderItem) {
+	for _, item := range items {
+		if item.SentToKitchen() && item.CurrentPrepStatus() != models.PrepStatusCancelled {
+			publishKitchenEvent(models.KitchenEventItemMoved, item)
+		}
+	}
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, value := range values {
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		unique = append(unique, value)
+	}
+	return unique
+}
@@ -0,0 +1,322 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"restaurant-management/database"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var stationCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionStations)
+
+// StationCount is how much work is waiting at a station. Items whose food has
+// no station are counted under an empty station id.
+type StationCount struct {
+	Station_id  string  `json:"station_id" bson:"_id"`
+	Name        *string `json:"name" bson:"name"`
+	Queued      int     `json:"queued" bson:"queued"`
+	Preparing   int     `json:"preparing" bson:"preparing"`
+	Outstanding int     `json:"outstanding" bson:"outstanding"`
+}
+
+// ExpoOrder shows the expediter which stations still owe items for an order.
+type ExpoOrder struct {
+	Order_id   string            `json:"order_id" bson:"_id"`
+	Table_id   *string           `json:"table_id" bson:"table_id"`
+	Status     *string           `json:"status" bson:"status"`
+	Ordered_at time.Time         `json:"ordered_at" bson:"ordered_at"`
+	Stations   []ExpoStationLine `json:"stations" bson:"stations"`
+	All_ready  bool              `json:"all_ready" bson:"all_ready"`
+}
+
+type ExpoStationLine struct {
+	Station_id string `json:"station_id" bson:"station_id"`
+	Items      int    `json:"items" bson:"items"`
+	Ready      int    `json:"ready" bson:"ready"`
+	Done       bool   `json:"done" bson:"done"`
+}
+
+// GET ALL STATIONS
+func GetStations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		cursor, err := stationCollection.Find(ctx, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		stations := []models.Station{}
+		if err := cursor.All(ctx, &stations); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, stations)
+	}
+}
+
+// CREATE STATION
+func CreateStation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var station models.Station
+		if err := c.BindJSON(&station); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(station); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		count, _ := stationCollection.CountDocuments(ctx, bson.M{"name": station.Name})
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "station already exists"})
+			return
+		}
+
+		station.ID = primitive.NewObjectID()
+		station.Station_id = station.ID.Hex()
+		station.Created_at = time.Now()
+		station.Updated_at = time.Now()
+
+		if _, err := stationCollection.InsertOne(ctx, station); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "station not created"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, station)
+	}
+}
+
+// UPDATE STATION
+func UpdateStation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var station models.Station
+		if err := c.BindJSON(&station); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(station); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, err := stationCollection.UpdateOne(
+			ctx,
+			bson.M{"station_id": c.Param("station_id")},
+			bson.D{{"$set", bson.D{{"name", station.Name}, {"updated_at", time.Now()}}}},
+		)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "station update failed"})
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "station not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// GET STATION COUNTS
+func GetStationCounts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		pipeline := mongo.Pipeline{
+			{{"$match", bson.M{
+				"prep_status": bson.M{"$nin": bson.A{models.PrepStatusReady, models.PrepStatusCancelled}},
+				"held":        bson.M{"$ne": true},
+			}}},
+			{{"$group", bson.M{
+				"_id":         bson.M{"$ifNull": bson.A{"$station_id", ""}},
+				"preparing":   bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$prep_status", models.PrepStatusPreparing}}, 1, 0}}},
+				"outstanding": bson.M{"$sum": 1},
+			}}},
+			{{"$lookup", bson.M{
+				"from":         database.CollectionStations,
+				"localField":   "_id",
+				"foreignField": "station_id",
+				"as":           "station",
+			}}},
+			{{"$project", bson.M{
+				"name":        bson.M{"$first": "$station.name"},
+				"preparing":   1,
+				"outstanding": 1,
+				"queued":      bson.M{"$subtract": bson.A{"$outstanding", "$preparing"}},
+			}}},
+			{{"$sort", bson.M{"_id": 1}}},
+		}
+
+		cursor, err := orderItemCollection.Aggregate(ctx, pipeline)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		counts := []StationCount{}
+		if err := cursor.All(ctx, &counts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, counts)
+	}
+}
+
+// GET EXPO VIEW
+func GetExpo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		pipeline := mongo.Pipeline{
+			{{"$match", bson.M{"prep_status": bson.M{"$ne": models.PrepStatusCancelled}, "held": bson.M{"$ne": true}}}},
+			{{"$group", bson.M{
+				"_id":   bson.M{"order_id": "$order_id", "station_id": bson.M{"$ifNull": bson.A{"$station_id", ""}}},
+				"items": bson.M{"$sum": 1},
+				"ready": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$prep_status", models.PrepStatusReady}}, 1, 0}}},
+			}}},
+			{{"$sort", bson.M{"_id.station_id": 1}}},
+			{{"$group", bson.M{
+				"_id": "$_id.order_id",
+				"stations": bson.M{"$push": bson.M{
+					"station_id": "$_id.station_id",
+					"items":      "$items",
+					"ready":      "$ready",
+					"done":       bson.M{"$eq": bson.A{"$items", "$ready"}},
+				}},
+				"items": bson.M{"$sum": "$items"},
+				"ready": bson.M{"$sum": "$ready"},
+			}}},
+			{{"$lookup", bson.M{
+				"from":         database.CollectionOrders,
+				"localField":   "_id",
+				"foreignField": "order_id",
+				"as":           "order",
+			}}},
+			{{"$unwind", "$order"}},
+			// once served the order has left the pass
+			{{"$match", bson.M{"order.status": bson.M{"$in": bson.A{
+				nil,
+				models.OrderStatusPlaced,
+				models.OrderStatusAccepted,
+				models.OrderStatusInPreparation,
+				models.OrderStatusReady,
+			}}}}},
+			{{"$project", bson.M{
+				"table_id":   "$order.table_id",
+				"status":     "$order.status",
+				"ordered_at": "$order.created_at",
+				"stations":   1,
+				"all_ready":  bson.M{"$eq": bson.A{"$items", "$ready"}},
+			}}},
+			{{"$sort", bson.M{"ordered_at": 1}}},
+		}
+
+		cursor, err := orderItemCollection.Aggregate(ctx, pipeline)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		orders := []ExpoOrder{}
+		if err := cursor.All(ctx, &orders); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, orders)
+	}
+}
+
+// routeOrderItems sets the station of each item from its food, falling back
+// to the default station of the food's menu. Items that resolve to neither
+// stay unrouted and only show up in the unfiltered kitchen queue.
+func routeOrderItems(ctx context.Context, items []models.OrderItem) error {
+	foodIds := bson.A{}
+	for _, item := range items {
+		if item.Food_id != nil {
+			foodIds = append(foodIds, *item.Food_id)
+		}
+	}
+
+	if len(foodIds) == 0 {
+		return nil
+	}
+
+	cursor, err := foodCollection.Find(ctx, bson.M{"food_id": bson.M{"$in": foodIds}})
+	if err != nil {
+		return err
+	}
+
+	var foods []models.Food
+	if err := cursor.All(ctx, &foods); err != nil {
+		return err
+	}
+
+	menuIds := bson.A{}
+	for _, food := range foods {
+		if food.Station_id == nil && food.Menu_id != nil {
+			menuIds = append(menuIds, *food.Menu_id)
+		}
+	}
+
+	menuStations := map[string]string{}
+	if len(menuIds) > 0 {
+		cursor, err := menuCollection.Find(ctx, bson.M{"menu_id": bson.M{"$in": menuIds}})
+		if err != nil {
+			return err
+		}
+
+		var menus []models.Menu
+		if err := cursor.All(ctx, &menus); err != nil {
+			return err
+		}
+
+		for _, menu := range menus {
+			if menu.Default_station_id != nil {
+				menuStations[menu.Menu_id] = *menu.Default_station_id
+			}
+		}
+	}
+
+	foodStations := map[string]string{}
+	for _, food := range foods {
+		if food.Station_id != nil {
+			foodStations[food.Food_id] = *food.Station_id
+		} else if food.Menu_id != nil {
+			foodStations[food.Food_id] = menuStations[*food.Menu_id]
+		}
+	}
+
+	for i := range items {
+		if items[i].Food_id != nil {
+			items[i].Station_id = foodStations[*items[i].Food_id]
+		}
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"time"
 
@@ -15,7 +16,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var tableCollection *mongo.Collection = database.OpenCollection(database.Client, "tables")
+var tableCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionTables)
 
 // GET ALL TABLES
 func GetTables() gin.HandlerFunc {
@@ -136,3 +137,46 @@ func UpdateTable() gin.HandlerFunc {
 		c.JSON(http.StatusOK, result)
 	}
 }
+
+// refreshTableOccupancy recounts the open orders on each table. Occupancy is
+// derived from the orders rather than adjusted step by step, so a refresh
+// after any write that opens, finishes or moves an order puts it right.
+func refreshTableOccupancy(ctx context.Context, tableIds ...*string) error {
+	seen := map[string]bool{}
+
+	for _, tableId := range tableIds {
+		if tableId == nil || seen[*tableId] {
+			continue
+		}
+		seen[*tableId] = true
+
+		open, err := orderCollection.CountDocuments(ctx, bson.M{
+			"table_id": *tableId,
+			"status":   bson.M{"$nin": models.FinishedOrderStatuses},
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tableCollection.UpdateOne(
+			ctx,
+			bson.M{"table_id": *tableId},
+			bson.D{{"$set", bson.D{
+				{"occupied", open > 0},
+				{"open_orders", open},
+				{"updated_at", time.Now()},
+			}}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func updateTableOccupancy(ctx context.Context, tableIds ...*string) {
+	if err := refreshTableOccupancy(ctx, tableIds...); err != nil {
+		log.Println("table occupancy:", err)
+	}
+}
@@ -0,0 +1,380 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"restaurant-management/database"
+	helper "restaurant-management/helpers"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var settingCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionSettings)
+
+const recoveryCodeCount = 10
+
+// GET TWO-FACTOR POLICY
+func GetTwoFactorPolicy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		policy, err := loadTwoFactorPolicy(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// UPDATE TWO-FACTOR POLICY
+func UpdateTwoFactorPolicy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var policy models.TwoFactorPolicy
+		if err := c.BindJSON(&policy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(policy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if policy.Required_roles == nil {
+			policy.Required_roles = []string{}
+		}
+		policy.Key = models.SettingTwoFactorPolicy
+		policy.Updated_by = c.GetString("uid")
+		policy.Updated_at = time.Now()
+
+		_, err := settingCollection.ReplaceOne(
+			ctx,
+			bson.M{"key": models.SettingTwoFactorPolicy},
+			policy,
+			options.Replace().SetUpsert(true),
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "policy update failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// SET UP TWO-FACTOR
+func SetupTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+		var user models.User
+
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		if user.Totp_enabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "two-factor authentication is already enabled"})
+			return
+		}
+
+		secret, err := helper.GenerateTOTPSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate secret"})
+			return
+		}
+
+		// stays pending until the user proves the authenticator works
+		_, err = userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": userId},
+			bson.D{{"$set", bson.D{
+				{"totp_secret", secret},
+				{"updated_at", time.Now()},
+			}}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "two-factor setup failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"secret":           secret,
+			"provisioning_uri": helper.TOTPProvisioningURI(secret, *user.Email),
+		})
+	}
+}
+
+// ENABLE TWO-FACTOR
+func EnableTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		var input struct {
+			Code *string `json:"code" validate:"required"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		if user.Totp_enabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "two-factor authentication is already enabled"})
+			return
+		}
+
+		if user.Totp_secret == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start two-factor setup first"})
+			return
+		}
+
+		step, ok := helper.ValidateTOTP(*user.Totp_secret, *input.Code, time.Now())
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+			return
+		}
+
+		codes, hashes, err := helper.GenerateRecoveryCodes(recoveryCodeCount)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate recovery codes"})
+			return
+		}
+
+		_, err = userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": userId},
+			bson.D{{"$set", bson.D{
+				{"totp_enabled", true},
+				{"totp_last_step", step},
+				{"recovery_codes", hashes},
+				{"updated_at", time.Now()},
+			}}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not enable two-factor authentication"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "two-factor authentication enabled, refresh your token to continue",
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// DISABLE TWO-FACTOR
+func DisableTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		var input struct {
+			Code          *string `json:"code"`
+			Recovery_code *string `json:"recovery_code"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		// admins can reset a lost authenticator; everyone else proves
+		// possession first
+		if c.GetString("uid") == userId && !verifySecondFactor(ctx, user, input.Code, input.Recovery_code) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+			return
+		}
+
+		_, err := userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": userId},
+			bson.D{
+				{"$set", bson.D{
+					{"totp_enabled", false},
+					{"updated_at", time.Now()},
+				}},
+				{"$unset", bson.D{
+					{"totp_secret", ""},
+					{"totp_last_step", ""},
+					{"recovery_codes", ""},
+				}},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not disable two-factor authentication"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+	}
+}
+
+// LOGIN SECOND STEP
+func VerifyLoginChallenge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var input struct {
+			Challenge_id  *string `json:"challenge_id" validate:"required"`
+			Code          *string `json:"code"`
+			Recovery_code *string `json:"recovery_code"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		challenge, err := helper.UseLoginChallenge(*input.Challenge_id)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": challenge.User_id}).Decode(&user); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+			return
+		}
+
+		ip := c.ClientIP()
+		if !verifySecondFactor(ctx, user, input.Code, input.Recovery_code) {
+			helper.RecordLoginFailure(ip, helper.AccountAttemptKey(*user.Email), helper.IPAttemptKey(ip))
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+			return
+		}
+
+		helper.DeleteLoginChallenge(*input.Challenge_id)
+		helper.ResetLoginFailures(helper.AccountAttemptKey(*user.Email))
+
+		if msg := inactiveAccountError(user); msg != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": msg})
+			return
+		}
+
+		// a terminal revoked while the code was being typed gets no session
+		if challenge.Device_id != "" {
+			count, err := deviceCollection.CountDocuments(ctx, bson.M{"device_id": challenge.Device_id, "revoked": false})
+			if err != nil || count == 0 {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "unknown or revoked device"})
+				return
+			}
+		}
+
+		completeLogin(ctx, c, user, challenge.Device_id)
+	}
+}
+
+// verifySecondFactor accepts either a current TOTP code, each time step only
+// once, or one of the single-use recovery codes.
+func verifySecondFactor(ctx context.Context, user models.User, code, recoveryCode *string) bool {
+	if !user.Totp_enabled || user.Totp_secret == nil {
+		return false
+	}
+
+	if code != nil {
+		step, ok := helper.ValidateTOTP(*user.Totp_secret, *code, time.Now())
+		if !ok {
+			return false
+		}
+
+		result, err := userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": user.User_id, "totp_last_step": bson.M{"$lt": step}},
+			bson.D{{"$set", bson.D{{"totp_last_step", step}}}},
+		)
+		return err == nil && result.ModifiedCount == 1
+	}
+
+	if recoveryCode != nil {
+		hash := helper.HashRecoveryCode(*recoveryCode)
+		result, err := userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": user.User_id, "recovery_codes": hash},
+			bson.D{{"$pull", bson.D{{"recovery_codes", hash}}}},
+		)
+		return err == nil && result.ModifiedCount == 1
+	}
+
+	return false
+}
+
+func loadTwoFactorPolicy(ctx context.Context) (models.TwoFactorPolicy, error) {
+	policy := models.TwoFactorPolicy{Required_roles: []string{}}
+
+	err := settingCollection.FindOne(ctx, bson.M{"key": models.SettingTwoFactorPolicy}).Decode(&policy)
+	if err == mongo.ErrNoDocuments {
+		return policy, nil
+	}
+
+	return policy, err
+}
+
+// totpSetupRequired tells whether the user's role demands two-factor
+// authentication that the user has not enrolled in yet.
+func totpSetupRequired(user models.User) bool {
+	if user.Totp_enabled {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	policy, err := loadTwoFactorPolicy(ctx)
+	if err != nil {
+		// fail closed: an unreadable policy must not waive the requirement
+		return true
+	}
+
+	for _, role := range policy.Required_roles {
+		if role == userRole(user) {
+			return true
+		}
+	}
+
+	return false
+}
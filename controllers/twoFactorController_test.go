@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// currentTOTP computes the code an authenticator app would show right now.
+func currentTOTP(t *testing.T, secret string) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(time.Now().Unix()/30))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+func TestVerifySecondFactorRejectsReplayedCode(t *testing.T) {
+	useTestDatabase(t)
+
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	user := models.User{
+		ID:           primitive.NewObjectID(),
+		User_id:      "user-1",
+		Totp_secret:  &secret,
+		Totp_enabled: true,
+		Created_at:   time.Now(),
+	}
+	insertAll(t, userCollection, user)
+
+	ctx := context.Background()
+	code := currentTOTP(t, secret)
+
+	if !verifySecondFactor(ctx, user, &code, nil) {
+		t.Fatal("a fresh code was rejected")
+	}
+	if verifySecondFactor(ctx, user, &code, nil) {
+		t.Error("the same code was accepted twice within its step")
+	}
+}
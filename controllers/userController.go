@@ -2,10 +2,13 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
 	"restaurant-management/database"
 	helper "restaurant-management/helpers"
 	"restaurant-management/models"
-	"net/http"
 	"strconv"
 	"time"
 
@@ -17,7 +20,64 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
+var userCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionUsers)
+
+// UserPublicView is what any signed-in colleague may see about a user.
+type UserPublicView struct {
+	User_id    string  `json:"user_id"`
+	First_name *string `json:"first_name"`
+	Last_name  *string `json:"last_name"`
+	Avatar     *string `json:"avatar"`
+	Role       *string `json:"role"`
+}
+
+// UserSelfView adds the contact details the account owner sees.
+type UserSelfView struct {
+	UserPublicView
+	Email          *string   `json:"email"`
+	Email_verified bool      `json:"email_verified"`
+	Totp_enabled   bool      `json:"totp_enabled"`
+	Phone          *string   `json:"phone"`
+	Created_at     time.Time `json:"created_at"`
+	Updated_at     time.Time `json:"updated_at"`
+}
+
+// UserAdminView is what admins and managers see when managing staff.
+type UserAdminView struct {
+	UserSelfView
+	Status        string     `json:"status"`
+	Last_login_at *time.Time `json:"last_login_at"`
+}
+
+func newUserPublicView(user models.User) UserPublicView {
+	return UserPublicView{
+		User_id:    user.User_id,
+		First_name: user.First_name,
+		Last_name:  user.Last_name,
+		Avatar:     user.Avatar,
+		Role:       user.Role,
+	}
+}
+
+func newUserSelfView(user models.User) UserSelfView {
+	return UserSelfView{
+		UserPublicView: newUserPublicView(user),
+		Email:          user.Email,
+		Email_verified: user.Email_verified,
+		Totp_enabled:   user.Totp_enabled,
+		Phone:          user.Phone,
+		Created_at:     user.Created_at,
+		Updated_at:     user.Updated_at,
+	}
+}
+
+func newUserAdminView(user models.User) UserAdminView {
+	return UserAdminView{
+		UserSelfView:  newUserSelfView(user),
+		Status:        userStatus(user),
+		Last_login_at: user.Last_login_at,
+	}
+}
 
 func GetUsers() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -46,7 +106,12 @@ func GetUsers() gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(http.StatusOK, users)
+		views := make([]UserAdminView, 0, len(users))
+		for _, user := range users {
+			views = append(views, newUserAdminView(user))
+		}
+
+		c.JSON(http.StatusOK, views)
 	}
 }
 
@@ -63,7 +128,65 @@ func GetUserById() gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(http.StatusOK, user)
+		switch c.GetString("role") {
+		case models.RoleAdmin, models.RoleManager:
+			c.JSON(http.StatusOK, newUserAdminView(user))
+		default:
+			c.JSON(http.StatusOK, newUserSelfView(user))
+		}
+	}
+}
+
+// claimAdminBootstrap decides whether this signup becomes the first admin.
+// Counting users alone is racy, so the winner also has to insert the
+// bootstrap marker; its fixed _id lets only one concurrent signup succeed.
+func claimAdminBootstrap(ctx context.Context, userId string) (bool, error) {
+	userCount, err := userCollection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return false, err
+	}
+	if userCount > 0 {
+		return false, nil
+	}
+
+	_, err = settingCollection.InsertOne(ctx, bson.M{
+		"_id":        models.SettingAdminBootstrap,
+		"key":        models.SettingAdminBootstrap,
+		"user_id":    userId,
+		"created_at": time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// releaseAdminBootstrap gives up the marker when the admin signup did not
+// go through, so the next signup can still bootstrap.
+func releaseAdminBootstrap(ctx context.Context) {
+	if _, err := settingCollection.DeleteOne(ctx, bson.M{"_id": models.SettingAdminBootstrap}); err != nil {
+		log.Println("could not release the admin bootstrap marker:", err)
+	}
+}
+
+// GET USER PROFILE
+func GetUserProfile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+		var user models.User
+
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, newUserPublicView(user))
 	}
 }
 
@@ -97,25 +220,52 @@ func SignUp() gin.HandlerFunc {
 
 		user.ID = primitive.NewObjectID()
 		user.User_id = user.ID.Hex()
+
+		// roles are handed out by admins; only the very first account
+		// bootstraps as ADMIN so someone can do that
+		role := models.RoleWaiter
+		bootstrapped, err := claimAdminBootstrap(ctx, user.User_id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not created"})
+			return
+		}
+		if bootstrapped {
+			role = models.RoleAdmin
+		}
+		user.Role = &role
+		user.Email_verified = false
+		user.Totp_enabled = false
+
+		status := models.UserStatusActive
+		user.Status = &status
 		user.Created_at = time.Now()
 		user.Updated_at = time.Now()
 
-		token, refresh, _ := helper.GenerateAllTokens(
-			*user.Email,
-			*user.First_name,
-			*user.Last_name,
-			user.User_id,
-		)
+		token, refresh, err := issueTokens(user, "")
+		if err != nil {
+			if bootstrapped {
+				releaseAdminBootstrap(ctx)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not issue tokens"})
+			return
+		}
 
 		user.Token = &token
 		user.Refresh_Token = &refresh
 
 		result, err := userCollection.InsertOne(ctx, user)
 		if err != nil {
+			if bootstrapped {
+				releaseAdminBootstrap(ctx)
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not created"})
 			return
 		}
 
+		if err := sendVerificationEmail(user); err != nil {
+			log.Println("verification mail failed:", err)
+		}
+
 		c.JSON(http.StatusCreated, result)
 	}
 }
@@ -133,33 +283,659 @@ func Login() gin.HandlerFunc {
 			return
 		}
 
+		if input.Email == nil || input.Password == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email and password are required"})
+			return
+		}
+
+		ip := c.ClientIP()
+		accountKey := helper.AccountAttemptKey(*input.Email)
+
+		// checked before bcrypt so a locked account costs us nothing
+		wait, err := helper.LoginBlockedFor(accountKey, helper.IPAttemptKey(ip))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "login unavailable"})
+			return
+		}
+		if wait > 0 {
+			abortTooManyAttempts(c, wait)
+			return
+		}
+
 		if err := userCollection.FindOne(ctx, bson.M{"email": input.Email}).Decode(&user); err != nil {
+			helper.RecordLoginFailure(ip, accountKey, helper.IPAttemptKey(ip))
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+			return
+		}
+
+		if user.Password == nil {
+			helper.RecordLoginFailure(ip, accountKey, helper.IPAttemptKey(ip))
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 			return
 		}
 
 		ok, _ := VerifyPassword(*input.Password, *user.Password)
 		if !ok {
+			helper.RecordLoginFailure(ip, accountKey, helper.IPAttemptKey(ip))
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 			return
 		}
 
-		token, refresh, _ := helper.GenerateAllTokens(
-			*user.Email,
-			*user.First_name,
-			*user.Last_name,
-			user.User_id,
-		)
+		if msg := inactiveAccountError(user); msg != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": msg})
+			return
+		}
+
+		// with two-factor enabled the password only buys a challenge;
+		// tokens are issued once the code checks out
+		if user.Totp_enabled {
+			challengeId, err := helper.CreateLoginChallenge(user.User_id, ip, "")
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not start two-factor login"})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"two_factor_required": true,
+				"challenge_id":        challengeId,
+			})
+			return
+		}
+
+		helper.ResetLoginFailures(accountKey)
+
+		completeLogin(ctx, c, user, "")
+	}
+}
+
+// completeLogin issues tokens to a fully authenticated user and responds
+// with them. A non-empty deviceId binds the session to that terminal.
+func completeLogin(ctx context.Context, c *gin.Context, user models.User, deviceId string) {
+	token, refresh, err := issueTokens(user, deviceId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not issue tokens"})
+		return
+	}
+
+	helper.UpdateAllTokens(token, refresh, user.User_id)
+
+	now := time.Now()
+	userCollection.UpdateOne(ctx, bson.M{"user_id": user.User_id}, bson.D{{"$set", bson.D{{"last_login_at", now}}}})
+	user.Last_login_at = &now
+
+	if deviceId != "" {
+		deviceCollection.UpdateOne(ctx, bson.M{"device_id": deviceId}, bson.D{{"$set", bson.D{{"last_used_at", now}}}})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":          newUserSelfView(user),
+		"token":         token,
+		"refresh_token": refresh,
+	})
+}
+
+// REFRESH TOKENS
+func RefreshToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var input struct {
+			Refresh_token *string `json:"refresh_token" validate:"required"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		claims, errMsg := helper.ValidateToken(*input.Refresh_token)
+		if errMsg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": errMsg})
+			return
+		}
+
+		if claims.Token_type != helper.TokenTypeRefresh || claims.Session_id == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token required"})
+			return
+		}
+
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": claims.Uid}).Decode(&user); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+			return
+		}
+
+		if msg := inactiveAccountError(user); msg != "" {
+			helper.RevokeSession(claims.Session_id)
+			c.JSON(http.StatusForbidden, gin.H{"error": msg})
+			return
+		}
+
+		deviceId := c.GetHeader(helper.DeviceIdHeader)
+		secret := c.GetHeader(helper.DeviceSecretHeader)
+		if errMsg = helper.CheckDeviceBinding(ctx, claims, deviceId, secret); errMsg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": errMsg})
+			return
+		}
+
+		details := userClaims(user, claims.Session_id)
+		details.Device_id = claims.Device_id
+
+		token, refresh, err := helper.GenerateAllTokens(details)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not issue tokens"})
+			return
+		}
+
+		err = helper.RotateSession(claims.Session_id, *input.Refresh_token, token, refresh)
+		if errors.Is(err, helper.ErrRefreshTokenReused) || errors.Is(err, helper.ErrSessionRevoked) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "token refresh failed"})
+			return
+		}
 
 		helper.UpdateAllTokens(token, refresh, user.User_id)
 
-		user.Token = &token
-		user.Refresh_Token = &refresh
+		c.JSON(http.StatusOK, gin.H{
+			"token":         token,
+			"refresh_token": refresh,
+		})
+	}
+}
 
-		c.JSON(http.StatusOK, user)
+// LOGOUT
+func Logout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := helper.RevokeSession(c.GetString("session_id")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "logout failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+	}
+}
+
+// REVOKE ALL SESSIONS
+func RevokeAllSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revoked, err := helper.RevokeAllSessions(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not revoke sessions"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"revoked_sessions": revoked})
 	}
 }
 
+// UPDATE USER PROFILE
+func UpdateUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		var input struct {
+			First_name *string `json:"first_name" validate:"omitempty,min=2,max=100"`
+			Last_name  *string `json:"last_name" validate:"omitempty,min=2,max=100"`
+			Email      *string `json:"email" validate:"omitempty,email"`
+			Phone      *string `json:"phone" validate:"omitempty,min=1"`
+			Avatar     *string `json:"avatar" validate:"omitempty,url"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		// whoever controls the email can reset the password, so editing a
+		// higher account would be a way into it
+		if outranksCaller(c, user) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you cannot edit a user with a higher role"})
+			return
+		}
+
+		previousEmail := user.Email
+
+		var updateObj primitive.D
+
+		if input.First_name != nil {
+			updateObj = append(updateObj, bson.E{"first_name", input.First_name})
+		}
+		if input.Last_name != nil {
+			updateObj = append(updateObj, bson.E{"last_name", input.Last_name})
+		}
+		if input.Avatar != nil {
+			updateObj = append(updateObj, bson.E{"avatar", input.Avatar})
+		}
+
+		if input.Phone != nil && (user.Phone == nil || *input.Phone != *user.Phone) {
+			count, _ := userCollection.CountDocuments(ctx, bson.M{"phone": input.Phone, "user_id": bson.M{"$ne": userId}})
+			if count > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "phone already exists"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"phone", input.Phone})
+		}
+
+		emailChanged := input.Email != nil && (user.Email == nil || *input.Email != *user.Email)
+		if emailChanged {
+			count, _ := userCollection.CountDocuments(ctx, bson.M{"email": input.Email, "user_id": bson.M{"$ne": userId}})
+			if count > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "email already exists"})
+				return
+			}
+			// a new address has to be confirmed again
+			updateObj = append(updateObj, bson.E{"email", input.Email}, bson.E{"email_verified", false})
+		}
+
+		if len(updateObj) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+			return
+		}
+
+		updateObj = append(updateObj, bson.E{"updated_at", time.Now()})
+
+		err := userCollection.FindOneAndUpdate(
+			ctx,
+			bson.M{"user_id": userId},
+			bson.D{{"$set", updateObj}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "user update failed"})
+			return
+		}
+
+		if emailChanged {
+			if err := sendVerificationEmail(user); err != nil {
+				log.Println("verification mail failed:", err)
+			}
+
+			// tell the old address, in case the change was not the owner's
+			if previousEmail != nil {
+				err := mailer.Send(
+					*previousEmail,
+					"Your email address was changed",
+					"The email address of your account was changed to "+*user.Email+
+						". If you did not do this, contact your administrator right away.",
+				)
+				if err != nil {
+					log.Println("email change notice failed:", err)
+				}
+			}
+
+			if _, err := helper.RevokeAllSessions(userId); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "email changed but sessions could not be revoked"})
+				return
+			}
+		}
+
+		if c.GetString("uid") == userId {
+			c.JSON(http.StatusOK, newUserSelfView(user))
+			return
+		}
+		c.JSON(http.StatusOK, newUserAdminView(user))
+	}
+}
+
+// DEACTIVATE USER
+func DeactivateUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		if userId == c.GetString("uid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot deactivate your own account"})
+			return
+		}
+
+		if err := setUserStatus(ctx, userId, models.UserStatusDeactivated); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		revoked, err := helper.RevokeAllSessions(userId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not revoke sessions"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "user deactivated", "revoked_sessions": revoked})
+	}
+}
+
+// REACTIVATE USER
+func ReactivateUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := setUserStatus(ctx, c.Param("user_id"), models.UserStatusActive); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "user reactivated"})
+	}
+}
+
+// CHANGE PASSWORD
+func ChangePassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		var input struct {
+			Current_password *string `json:"current_password"`
+			New_password     *string `json:"new_password" validate:"required,min=6"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		// admins resetting someone else's password skip the current one
+		if c.GetString("uid") == userId {
+			if input.Current_password == nil || user.Password == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "current_password is required"})
+				return
+			}
+
+			if ok, msg := VerifyPassword(*input.Current_password, *user.Password); !ok {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+				return
+			}
+		}
+
+		if err := setPassword(ctx, userId, *input.New_password); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "password update failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "password changed, please log in again"})
+	}
+}
+
+// UNLOCK USER
+func UnlockUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+		var user models.User
+
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil || user.Email == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		if err := helper.ResetLoginFailures(helper.AccountAttemptKey(*user.Email)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "unlock failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "account unlocked"})
+	}
+}
+
+// GET LOCKOUT EVENTS
+func GetLockoutEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if limit < 1 {
+			limit = 50
+		}
+
+		events, err := helper.GetLockoutEvents(int64(limit))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, events)
+	}
+}
+
+// UPDATE USER ROLE
+func UpdateUserRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+
+		var input struct {
+			Role *string `json:"role" validate:"required,eq=ADMIN|eq=MANAGER|eq=WAITER|eq=CASHIER|eq=KITCHEN"`
+		}
+
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if userId == c.GetString("uid") && *input.Role != models.RoleAdmin {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "admins cannot demote themselves"})
+			return
+		}
+
+		var previous models.User
+		err := userCollection.FindOneAndUpdate(
+			ctx,
+			bson.M{"user_id": userId},
+			bson.D{{"$set", bson.D{
+				{"role", input.Role},
+				{"updated_at", time.Now()},
+			}}},
+		).Decode(&previous)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "role update failed"})
+			return
+		}
+
+		if userRole(previous) == *input.Role {
+			c.JSON(http.StatusOK, gin.H{"message": "role unchanged", "revoked_sessions": 0})
+			return
+		}
+
+		// the role is a token claim, so tokens issued before the change
+		// would keep the old permissions until they expire
+		revoked, err := helper.RevokeAllSessions(userId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "role updated but sessions could not be revoked"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "role updated", "revoked_sessions": revoked})
+	}
+}
+
+func userStatus(user models.User) string {
+	if user.Status == nil {
+		return models.UserStatusActive
+	}
+	return *user.Status
+}
+
+// inactiveAccountError explains why the account may not sign in, or returns
+// an empty string when it may.
+func inactiveAccountError(user models.User) string {
+	switch userStatus(user) {
+	case models.UserStatusDeactivated:
+		return "account has been deactivated"
+	case models.UserStatusPending:
+		return "accept your invitation before signing in"
+	}
+	return ""
+}
+
+func setUserStatus(ctx context.Context, userId, status string) error {
+	update := bson.D{{"$set", bson.D{
+		{"status", status},
+		{"updated_at", time.Now()},
+	}}}
+
+	if status == models.UserStatusDeactivated {
+		update = append(update, bson.E{"$unset", bson.D{
+			{"token", ""},
+			{"refresh_token", ""},
+		}})
+	}
+
+	// pending invitations are activated by accepting them, not by an admin
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.M{"user_id": userId, "status": bson.M{"$ne": models.UserStatusPending}},
+		update,
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New("user not found or invitation still pending")
+	}
+
+	return nil
+}
+
+func userRole(user models.User) string {
+	if user.Role == nil {
+		return ""
+	}
+	return *user.Role
+}
+
+// outranksCaller reports whether the user holds a higher role than whoever
+// is making the request, who then may not manage their account.
+func outranksCaller(c *gin.Context, user models.User) bool {
+	return models.RoleRank(userRole(user)) > models.RoleRank(c.GetString("role"))
+}
+
+func abortTooManyAttempts(c *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "too many failed attempts, try again later",
+		"retry_after": seconds,
+	})
+}
+
+// setPassword stores a new password hash and signs the user out of every
+// session, since any of them may belong to whoever knew the old password.
+func setPassword(ctx context.Context, userId, password string) error {
+	_, err := userCollection.UpdateOne(
+		ctx,
+		bson.M{"user_id": userId},
+		bson.D{
+			{"$set", bson.D{
+				{"password", HashPassword(password)},
+				{"updated_at", time.Now()},
+			}},
+			{"$unset", bson.D{
+				{"token", ""},
+				{"refresh_token", ""},
+			}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = helper.RevokeAllSessions(userId)
+	return err
+}
+
+// issueTokens starts a new session for the user and returns its first
+// access/refresh token pair. A non-empty deviceId binds the session to that
+// terminal.
+func issueTokens(user models.User, deviceId string) (string, string, error) {
+	sessionId := primitive.NewObjectID().Hex()
+
+	details := userClaims(user, sessionId)
+	details.Device_id = deviceId
+
+	token, refresh, err := helper.GenerateAllTokens(details)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := helper.StartSession(sessionId, user.User_id, deviceId, token, refresh); err != nil {
+		return "", "", err
+	}
+
+	return token, refresh, nil
+}
+
+func userClaims(user models.User, sessionId string) helper.SignedDetails {
+	details := helper.SignedDetails{
+		Uid:                 user.User_id,
+		Role:                userRole(user),
+		Email_verified:      user.Email_verified,
+		Totp_setup_required: totpSetupRequired(user),
+		Session_id:          sessionId,
+	}
+
+	if user.Email != nil {
+		details.Email = *user.Email
+	}
+	if user.First_name != nil {
+		details.First_name = *user.First_name
+	}
+	if user.Last_name != nil {
+		details.Last_name = *user.Last_name
+	}
+
+	return details
+}
+
 func HashPassword(password string) string {
 	bytes, _ := bcrypt.GenerateFromPassword([]byte(password), 14)
 	return string(bytes)
@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// secretUser has every secret field set to a value that must never show up
+// in a response.
+func secretUser() models.User {
+	str := func(s string) *string { return &s }
+	now := time.Now()
+
+	return models.User{
+		ID:             primitive.NewObjectID(),
+		User_id:        "user-1",
+		First_name:     str("Ada"),
+		Last_name:      str("Lovelace"),
+		Email:          str("ada@example.com"),
+		Phone:          str("555-0100"),
+		Role:           str(models.RoleAdmin),
+		Status:         str(models.UserStatusActive),
+		Password:       str("secret-password-hash"),
+		Token:          str("secret-access-token"),
+		Refresh_Token:  str("secret-refresh-token"),
+		Pin_hash:       str("secret-pin-hash"),
+		Totp_secret:    str("secret-totp-seed"),
+		Totp_enabled:   true,
+		Totp_last_step: 424242,
+		Recovery_codes: []string{"secret-recovery-code"},
+		Last_login_at:  &now,
+		Created_at:     now,
+		Updated_at:     now,
+	}
+}
+
+var secretValues = []string{
+	"secret-password-hash",
+	"secret-access-token",
+	"secret-refresh-token",
+	"secret-pin-hash",
+	"secret-totp-seed",
+	"secret-recovery-code",
+	"424242",
+}
+
+// secretKeys may not even appear as empty fields in a view.
+var secretKeys = []string{
+	`"password"`,
+	`"token"`,
+	`"refresh_token"`,
+	`"pin_hash"`,
+	`"totp_secret"`,
+	`"totp_last_step"`,
+	`"recovery_codes"`,
+}
+
+func TestUserResponsesLeakNoSecrets(t *testing.T) {
+	user := secretUser()
+
+	views := map[string]interface{}{
+		"public view": newUserPublicView(user),
+		"self view":   newUserSelfView(user),
+		"admin view":  newUserAdminView(user),
+		"admin list":  []UserAdminView{newUserAdminView(user)},
+	}
+
+	for name, view := range views {
+		out, err := json.Marshal(view)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		assertNoSecrets(t, name, out)
+	}
+}
+
+func TestUserMarshalJSONBlanksSecrets(t *testing.T) {
+	user := secretUser()
+
+	for name, value := range map[string]interface{}{
+		"user":         user,
+		"user pointer": &user,
+		"user list":    []models.User{user},
+	} {
+		out, err := json.Marshal(value)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		for _, secret := range secretValues {
+			if strings.Contains(string(out), secret) {
+				t.Errorf("%s exposes %s: %s", name, secret, out)
+			}
+		}
+	}
+
+	if user.Password == nil || user.Token == nil {
+		t.Error("MarshalJSON must not clear the secrets on the caller's copy")
+	}
+}
+
+// assertNoSecrets fails when a response body carries any secret value or key.
+func assertNoSecrets(t *testing.T, name string, body []byte) {
+	t.Helper()
+
+	lower := strings.ToLower(string(body))
+	for _, secret := range append(secretValues, secretKeys...) {
+		if strings.Contains(lower, secret) {
+			t.Errorf("%s exposes %s: %s", name, secret, body)
+		}
+	}
+}
+
+func TestGetUsersHandler(t *testing.T) {
+	useTestDatabase(t)
+	insertAll(t, userCollection, secretUser())
+
+	rec := serve(GetUsers(), http.MethodGet, "/users", "/users", nil, gin.H{
+		"uid":  "admin-1",
+		"role": models.RoleAdmin,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+
+	var users []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 {
+		t.Fatalf("got %d users, want 1: %s", len(users), rec.Body)
+	}
+	if users[0]["user_id"] != "user-1" || users[0]["email"] != "ada@example.com" {
+		t.Errorf("unexpected user in list: %v", users[0])
+	}
+	assertNoSecrets(t, "GET /users", rec.Body.Bytes())
+}
+
+func TestGetUserByIdHandler(t *testing.T) {
+	useTestDatabase(t)
+	insertAll(t, userCollection, secretUser())
+
+	cases := []struct {
+		name      string
+		role      string
+		wantKeys  []string
+		wantNoKey []string
+	}{
+		{"admin", models.RoleAdmin, []string{"user_id", "email", "role", "status"}, nil},
+		{"waiter", models.RoleWaiter, []string{"user_id", "email"}, []string{"status", "last_login_at"}},
+	}
+
+	for _, tc := range cases {
+		rec := serve(GetUserById(), http.MethodGet, "/users/:user_id", "/users/user-1", nil, gin.H{
+			"uid":  "user-1",
+			"role": tc.role,
+		})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got %d: %s", tc.name, rec.Code, rec.Body)
+		}
+
+		var user map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &user); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range tc.wantKeys {
+			if _, ok := user[key]; !ok {
+				t.Errorf("%s: response has no %q: %s", tc.name, key, rec.Body)
+			}
+		}
+		for _, key := range tc.wantNoKey {
+			if _, ok := user[key]; ok {
+				t.Errorf("%s: response should not carry %q: %s", tc.name, key, rec.Body)
+			}
+		}
+		assertNoSecrets(t, tc.name, rec.Body.Bytes())
+	}
+
+	rec := serve(GetUserById(), http.MethodGet, "/users/:user_id", "/users/missing", nil, gin.H{
+		"uid":  "admin-1",
+		"role": models.RoleAdmin,
+	})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing user: got %d, want 404", rec.Code)
+	}
+}
@@ -1,5 +1,110 @@
 package controllers
 
-import "github.com/go-playground/validator/v10"
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
 
-var validate = validator.New()
\ No newline at end of file
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var validate = validator.New()
+
+// reference is an id in a request body that has to point at an existing
+// record. Field names the request field so the client knows which one to fix.
+type reference struct {
+	field      string
+	kind       string
+	collection *mongo.Collection
+	key        string
+	id         *string
+}
+
+func tableRef(field string, id *string) reference {
+	return reference{field, "table", tableCollection, "table_id", id}
+}
+
+func foodRef(field string, id *string) reference {
+	return reference{field, "food", foodCollection, "food_id", id}
+}
+
+func menuRef(field string, id *string) reference {
+	return reference{field, "menu", menuCollection, "menu_id", id}
+}
+
+func stationRef(field string, id *string) reference {
+	return reference{field, "station", stationCollection, "station_id", id}
+}
+
+// checkReferences looks every reference up, one query per kind, and returns
+// an error message for each field whose record does not exist. References
+// without an id are skipped; whether a field is required is checked
+// elsewhere.
+func checkReferences(ctx context.Context, refs ...reference) (map[string]string, error) {
+	ids := map[string]bson.A{}
+	byKind := map[string]reference{}
+
+	for _, ref := range refs {
+		if ref.id == nil {
+			continue
+		}
+		ids[ref.kind] = append(ids[ref.kind], *ref.id)
+		byKind[ref.kind] = ref
+	}
+
+	found := map[string]map[string]bool{}
+	for kind, kindIds := range ids {
+		ref := byKind[kind]
+
+		existing, err := ref.collection.Distinct(ctx, ref.key, bson.M{ref.key: bson.M{"$in": kindIds}})
+		if err != nil {
+			return nil, err
+		}
+
+		found[kind] = map[string]bool{}
+		for _, id := range existing {
+			if s, ok := id.(string); ok {
+				found[kind][s] = true
+			}
+		}
+	}
+
+	invalid := map[string]string{}
+	for _, ref := range refs {
+		if ref.id != nil && !found[ref.kind][*ref.id] {
+			invalid[ref.field] = ref.kind + " " + *ref.id + " not found"
+		}
+	}
+
+	return invalid, nil
+}
+
+// validateReferences writes the error response itself and reports whether
+// the request may go on.
+func validateReferences(ctx context.Context, c *gin.Context, refs ...reference) bool {
+	invalid, err := checkReferences(ctx, refs...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not check references"})
+		return false
+	}
+
+	if len(invalid) > 0 {
+		fields := []string{}
+		for field := range invalid {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "invalid reference in " + strings.Join(fields, ", "),
+			"fields": invalid,
+		})
+		return false
+	}
+
+	return true
+}
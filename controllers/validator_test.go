@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCheckReferences(t *testing.T) {
+	useTestDatabase(t)
+
+	insertAll(t, tableCollection, bson.M{"table_id": "table-1"}, bson.M{"table_id": "table-2"})
+	insertAll(t, foodCollection, bson.M{"food_id": "food-1"})
+
+	id := func(s string) *string { return &s }
+
+	cases := []struct {
+		name string
+		refs []reference
+		want map[string]string
+	}{
+		{
+			name: "existing id",
+			refs: []reference{tableRef("table_id", id("table-1"))},
+			want: map[string]string{},
+		},
+		{
+			name: "missing id",
+			refs: []reference{tableRef("table_id", id("table-9"))},
+			want: map[string]string{"table_id": "table table-9 not found"},
+		},
+		{
+			name: "nil id is skipped",
+			refs: []reference{foodRef("food_id", nil), tableRef("table_id", id("table-1"))},
+			want: map[string]string{},
+		},
+		{
+			name: "several fields of one kind",
+			refs: []reference{
+				tableRef("table_id", id("table-1")),
+				tableRef("to_table_id", id("table-9")),
+				tableRef("from_table_id", id("table-2")),
+			},
+			want: map[string]string{"to_table_id": "table table-9 not found"},
+		},
+		{
+			name: "several kinds",
+			refs: []reference{
+				tableRef("table_id", id("table-9")),
+				foodRef("food_id", id("food-1")),
+				menuRef("menu_id", id("menu-9")),
+			},
+			want: map[string]string{
+				"table_id": "table table-9 not found",
+				"menu_id":  "menu menu-9 not found",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := checkReferences(context.Background(), tc.refs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,494 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"restaurant-management/database"
+	helper "restaurant-management/helpers"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// voidRequest is the body of a void or cancellation. Manager_id and
+// Manager_pin are only needed when the kitchen may already be working on
+// the items and the caller is not a manager. Quantity voids only part of an
+// item and is ignored when cancelling an order.
+type voidRequest struct {
+	Reason      *string `json:"reason" validate:"required,eq=GUEST_REQUEST|eq=WRONG_ITEM|eq=KITCHEN_ERROR|eq=QUALITY|eq=DUPLICATE|eq=OTHER"`
+	Note        string  `json:"note" validate:"max=200"`
+	Quantity    *int    `json:"quantity" validate:"omitempty,min=1"`
+	Manager_id  *string `json:"manager_id"`
+	Manager_pin *string `json:"manager_pin"`
+}
+
+// VoidStats is how much one staff member voided in a period.
+type VoidStats struct {
+	User_id    string             `json:"user_id" bson:"_id"`
+	First_name *string            `json:"first_name" bson:"first_name"`
+	Last_name  *string            `json:"last_name" bson:"last_name"`
+	Items      int                `json:"items" bson:"items"`
+	Amount     float64            `json:"amount" bson:"amount"`
+	Approved   int                `json:"approved" bson:"approved"`
+	Reasons    []VoidReasonCounts `json:"reasons" bson:"reasons"`
+}
+
+type VoidReasonCounts struct {
+	Reason string  `json:"reason" bson:"reason"`
+	Items  int     `json:"items" bson:"items"`
+	Amount float64 `json:"amount" bson:"amount"`
+}
+
+// VOID ORDER ITEM
+func VoidOrderItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orderItemId := c.Param("orderItem_id")
+
+		input, ok := bindVoidRequest(c)
+		if !ok {
+			return
+		}
+
+		var item models.OrderItem
+		if err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&item); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order item not found"})
+			return
+		}
+
+		if item.Void != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "order item is already voided"})
+			return
+		}
+
+		if !itemsChangeable(ctx, c, item.Order_id) {
+			return
+		}
+
+		if input.Quantity != nil && *input.Quantity > item.CurrentQuantity() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "quantity is more than the item has"})
+			return
+		}
+
+		approvedBy := ""
+		if item.SentToKitchen() {
+			if approvedBy, ok = managerApproval(ctx, c, input); !ok {
+				return
+			}
+		}
+
+		void := newVoid(c, input, approvedBy)
+
+		if input.Quantity != nil && *input.Quantity < item.CurrentQuantity() {
+			voided, err := voidPartOfOrderItem(ctx, item, *input.Quantity, void)
+			if err == errOrderChanged {
+				c.JSON(http.StatusConflict, gin.H{"error": "order item changed meanwhile, reload and try again"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "order item could not be voided"})
+				return
+			}
+
+			c.JSON(http.StatusOK, voided)
+			return
+		}
+
+		voided, err := voidOrderItems(ctx, bson.M{"order_item_id": orderItemId}, void)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "order item could not be voided"})
+			return
+		}
+		if len(voided) == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "order item is already voided"})
+			return
+		}
+
+		c.JSON(http.StatusOK, voided[0])
+	}
+}
+
+// CANCEL ORDER
+func CancelOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orderId := c.Param("order_id")
+
+		input, ok := bindVoidRequest(c)
+		if !ok {
+			return
+		}
+
+		var order models.Order
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+			return
+		}
+
+		if !models.CanTransitionOrder(order.CurrentStatus(), models.OrderStatusCancelled) {
+			c.JSON(http.StatusConflict, gin.H{"error": "an order that is " + order.CurrentStatus() + " cannot be cancelled"})
+			return
+		}
+
+		sent, err := orderItemCollection.CountDocuments(ctx, bson.M{
+			"order_id": orderId,
+			"void":     nil,
+			"held":     bson.M{"$ne": true},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		approvedBy := ""
+		if sent > 0 {
+			if approvedBy, ok = managerApproval(ctx, c, input); !ok {
+				return
+			}
+		}
+
+		order, err = transitionOrder(ctx, order, models.OrderStatusCancelled, *input.Reason, c.GetString("uid"))
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusConflict, gin.H{"error": "order status changed meanwhile, reload and try again"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "order could not be cancelled"})
+			return
+		}
+
+		updateTableOccupancy(ctx, order.Table_id)
+
+		void := newVoid(c, input, approvedBy)
+		if _, err := voidOrderItems(ctx, bson.M{"order_id": orderId}, void); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "order cancelled but its items are still queued in the kitchen"})
+			return
+		}
+
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// GET VOID STATS
+func GetVoidStats() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		period := bson.M{}
+		for param, op := range map[string]string{"from": "$gte", "to": "$lt"} {
+			value := c.Query(param)
+			if value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be an RFC 3339 time"})
+				return
+			}
+			period[op] = t
+		}
+
+		match := bson.M{"void": bson.M{"$ne": nil}}
+		if len(period) > 0 {
+			match["void.voided_at"] = period
+		}
+
+		amount := bson.M{"$multiply": bson.A{
+			bson.M{"$ifNull": bson.A{"$unit_price", 0}},
+			bson.M{"$ifNull": bson.A{"$quantity", 1}},
+		}}
+
+		pipeline := mongo.Pipeline{
+			{{"$match", match}},
+			{{"$group", bson.M{
+				"_id":      bson.M{"user_id": "$void.voided_by", "reason": "$void.reason"},
+				"items":    bson.M{"$sum": 1},
+				"amount":   bson.M{"$sum": amount},
+				"approved": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$void.approved_by", ""}}, 1, 0}}},
+			}}},
+			{{"$sort", bson.M{"_id.reason": 1}}},
+			{{"$group", bson.M{
+				"_id":      "$_id.user_id",
+				"items":    bson.M{"$sum": "$items"},
+				"amount":   bson.M{"$sum": "$amount"},
+				"approved": bson.M{"$sum": "$approved"},
+				"reasons": bson.M{"$push": bson.M{
+					"reason": "$_id.reason",
+					"items":  "$items",
+					"amount": bson.M{"$round": bson.A{"$amount", 2}},
+				}},
+			}}},
+			{{"$lookup", bson.M{
+				"from":         database.CollectionUsers,
+				"localField":   "_id",
+				"foreignField": "user_id",
+				"as":           "user",
+			}}},
+			{{"$project", bson.M{
+				"first_name": bson.M{"$first": "$user.first_name"},
+				"last_name":  bson.M{"$first": "$user.last_name"},
+				"items":      1,
+				"amount":     bson.M{"$round": bson.A{"$amount", 2}},
+				"approved":   1,
+				"reasons":    1,
+			}}},
+			{{"$sort", bson.M{"amount": -1}}},
+		}
+
+		cursor, err := orderItemCollection.Aggregate(ctx, pipeline)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		stats := []VoidStats{}
+		if err := cursor.All(ctx, &stats); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, stats)
+	}
+}
+
+func bindVoidRequest(c *gin.Context) (voidRequest, bool) {
+	var input voidRequest
+
+	if err := c.BindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return input, false
+	}
+
+	if input.Reason != nil {
+		reason := strings.ToUpper(*input.Reason)
+		input.Reason = &reason
+	}
+
+	if err := validate.Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return input, false
+	}
+
+	if *input.Reason == models.VoidReasonOther && strings.TrimSpace(input.Note) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a note is required when the reason is OTHER"})
+		return input, false
+	}
+
+	return input, true
+}
+
+// managerApproval decides who signs off a void of items the kitchen may
+// already be working on. Managers approve their own; anyone else needs a
+// manager to enter their PIN with the request. Wrong PINs count against the
+// manager like PIN logins do. It writes the error response itself.
+func managerApproval(ctx context.Context, c *gin.Context, input voidRequest) (string, bool) {
+	role := c.GetString("role")
+	if role == models.RoleAdmin || role == models.RoleManager {
+		return c.GetString("uid"), true
+	}
+
+	if input.Manager_id == nil || input.Manager_pin == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "the kitchen already has this, a manager has to approve with their PIN"})
+		return "", false
+	}
+
+	key := helper.ApprovalAttemptKey(*input.Manager_id)
+
+	wait, err := helper.LoginBlockedFor(key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "approval unavailable"})
+		return "", false
+	}
+	if wait > 0 {
+		abortTooManyAttempts(c, wait)
+		return "", false
+	}
+
+	var manager models.User
+	err = userCollection.FindOne(ctx, bson.M{"user_id": input.Manager_id}).Decode(&manager)
+	if err != nil || manager.Pin_hash == nil ||
+		bcrypt.CompareHashAndPassword([]byte(*manager.Pin_hash), []byte(*input.Manager_pin)) != nil {
+		helper.RecordLoginFailure(c.ClientIP(), key)
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager approval failed"})
+		return "", false
+	}
+
+	helper.ResetLoginFailures(key)
+
+	managerRole := userRole(manager)
+	if (managerRole != models.RoleAdmin && managerRole != models.RoleManager) || inactiveAccountError(manager) != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager approval failed"})
+		return "", false
+	}
+
+	return manager.User_id, true
+}
+
+// voidPartOfOrderItem splits the voided portions off into an item of their
+// own, so they are reported like any other void while the rest stays on the
+// bill and in the kitchen. It returns the voided item.
+func voidPartOfOrderItem(ctx context.Context, item models.OrderItem, quantity int, void models.OrderItemVoid) (models.OrderItem, error) {
+	remaining := item.CurrentQuantity() - quantity
+	cancelled := models.PrepStatusCancelled
+
+	voided := item
+	voided.ID = primitive.NewObjectID()
+	voided.Order_item_id = voided.ID.Hex()
+	voided.Quantity = &quantity
+	voided.Void = &void
+	voided.Prep_status = &cancelled
+	voided.Updated_at = void.Voided_at
+
+	reduced := false
+	err := helper.RunAtomic(
+		ctx,
+		func(ctx context.Context) error {
+			result, err := orderItemCollection.UpdateOne(
+				ctx,
+				bson.M{"order_item_id": item.Order_item_id, "void": nil, "quantity": item.Quantity},
+				bson.D{{"$set", bson.D{{"quantity", remaining}, {"updated_at", void.Voided_at}}}},
+			)
+			if err != nil {
+				return err
+			}
+			if result.MatchedCount == 0 {
+				return errOrderChanged
+			}
+			reduced = true
+
+			_, err = orderItemCollection.InsertOne(ctx, voided)
+			return err
+		},
+		func(ctx context.Context) error {
+			if !reduced {
+				return nil
+			}
+			_, err := orderItemCollection.UpdateOne(
+				ctx,
+				bson.M{"order_item_id": item.Order_item_id, "quantity": remaining},
+				bson.D{{"$set", bson.D{{"quantity", item.Quantity}}}},
+			)
+			return err
+		},
+	)
+	if err != nil {
+		return voided, err
+	}
+
+	if item.SentToKitchen() {
+		item.Quantity = &remaining
+		item.Updated_at = void.Voided_at
+		publishKitchenEvent(models.KitchenEventItemUpdated, item)
+	}
+
+	return voided, nil
+}
+
+// itemsChangeable writes the error response itself and reports whether the
+// order's items may still be voided or edited. A finished order is done
+// with, and an invoice is computed from the items whenever it is read, so a
+// change after billing would silently alter an issued bill.
+func itemsChangeable(ctx context.Context, c *gin.Context, orderId string) bool {
+	var order models.Order
+	err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if !order.IsOpen() {
+		c.JSON(http.StatusConflict, gin.H{"error": "order is " + order.CurrentStatus()})
+		return false
+	}
+
+	invoiced, err := invoiceCollection.CountDocuments(ctx, bson.M{"order_id": orderId}, options.Count().SetLimit(1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if invoiced > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "order has been invoiced, its items can no longer change"})
+		return false
+	}
+
+	return true
+}
+
+func newVoid(c *gin.Context, input voidRequest, approvedBy string) models.OrderItemVoid {
+	return models.OrderItemVoid{
+		Reason:      *input.Reason,
+		Note:        strings.TrimSpace(input.Note),
+		Voided_by:   c.GetString("uid"),
+		Approved_by: approvedBy,
+		Voided_at:   time.Now(),
+	}
+}
+
+// voidOrderItems voids the matching items that are not voided yet, takes
+// them off the kitchen queues and returns them as they are now.
+func voidOrderItems(ctx context.Context, filter bson.M, void models.OrderItemVoid) ([]models.OrderItem, error) {
+	filter["void"] = nil
+
+	cursor, err := orderItemCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []models.OrderItem
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	ids := bson.A{}
+	for _, item := range items {
+		ids = append(ids, item.Order_item_id)
+	}
+
+	if len(ids) == 0 {
+		return items, nil
+	}
+
+	_, err = orderItemCollection.UpdateMany(
+		ctx,
+		bson.M{"order_item_id": bson.M{"$in": ids}, "void": nil},
+		bson.D{{"$set", bson.D{
+			{"void", void},
+			{"prep_status", models.PrepStatusCancelled},
+			{"updated_at", void.Voided_at},
+		}}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled := models.PrepStatusCancelled
+	for i := range items {
+		items[i].Void = &void
+		items[i].Prep_status = &cancelled
+		items[i].Updated_at = void.Voided_at
+
+		// held items never reached the kitchen
+		if items[i].SentToKitchen() {
+			publishKitchenEvent(models.KitchenEventItemCancelled, items[i])
+		}
+	}
+
+	return items, nil
+}
@@ -0,0 +1,27 @@
+package database
+
+// Collection names. Handles are opened with these, and aggregation stages
+// that join collections ($lookup) must use them too, since MongoDB silently
+// joins nothing when a name is wrong.
+const (
+	CollectionUsers           = "user"
+	CollectionSessions        = "sessions"
+	CollectionRevokedTokens   = "revoked_tokens"
+	CollectionLoginAttempts   = "login_attempts"
+	CollectionLockoutEvents   = "lockout_events"
+	CollectionUserTokens      = "user_tokens"
+	CollectionLoginChallenges = "login_challenges"
+	CollectionSettings        = "settings"
+	CollectionDevices         = "devices"
+	CollectionMenus           = "menus"
+	CollectionFoods           = "food"
+	CollectionTables          = "tables"
+	CollectionOrders          = "orders"
+	CollectionOrderItems      = "order_items"
+	CollectionInvoices        = "invoices"
+	CollectionNotes           = "notes"
+	CollectionStations        = "stations"
+	CollectionKitchenEvents   = "kitchen_events"
+	CollectionCounters        = "counters"
+	CollectionIdempotencyKeys = "idempotency_keys"
+)
@@ -1,34 +1,38 @@
 package database
 
 import (
-	"os"
-	"time"
-	"log"
-	"fmt"
 	"context"
+	"fmt"
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"os"
+	"time"
 )
 
-func DBinstance() *mongo.Client{
-	err := godotenv.Load(".env")
-	if err != nil{
-		log.Fatal("Error loading .env file")
+func DBinstance() *mongo.Client {
+	// the environment may already carry the settings, e.g. in tests or
+	// containers, so a missing .env file is not fatal
+	if err := godotenv.Load(".env"); err != nil {
+		log.Println("no .env file, using the environment")
 	}
 	Mongo_DB := os.Getenv("MONGODB_URL")
+	if Mongo_DB == "" {
+		Mongo_DB = "mongodb://localhost:27017"
+	}
 
-	client , err := mongo.NewClient(options.Client().ApplyURI(Mongo_DB))
+	client, err := mongo.NewClient(options.Client().ApplyURI(Mongo_DB))
 
-	if err != nil{
+	if err != nil {
 		log.Fatal(err)
 	}
 
-	ctx , cancel := context.WithTimeout(context.Background() , 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 
 	defer cancel()
 	err = client.Connect(ctx)
-	if err != nil{
+	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("Connected to mongo db")
@@ -36,11 +40,10 @@ func DBinstance() *mongo.Client{
 	return client
 }
 
-
 var Client *mongo.Client = DBinstance()
 
-func OpenCollection (client *mongo.Client , collectionName string) *mongo.Collection {
+func OpenCollection(client *mongo.Client, collectionName string) *mongo.Collection {
 	dbName := os.Getenv("MONGO_DB_NAME")
 	var collection *mongo.Collection = client.Database(dbName).Collection(collectionName)
 	return collection
-}
\ No newline at end of file
+}
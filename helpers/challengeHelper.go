@@ -0,0 +1,100 @@
+package helper
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"restaurant-management/database"
+	"restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var loginChallengeCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionLoginChallenges)
+
+const (
+	loginChallengeTTL         = 5 * time.Minute
+	maxLoginChallengeAttempts = 5
+)
+
+var ErrInvalidLoginChallenge = errors.New("login challenge is invalid or expired, log in again")
+
+// CreateLoginChallenge parks a password-verified login until the second
+// factor arrives and returns the challenge ID the client has to send back.
+// deviceId is empty unless the login started with a PIN on a terminal.
+func CreateLoginChallenge(userId, ip, deviceId string) (string, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	challengeId := hex.EncodeToString(raw)
+
+	now := time.Now()
+	_, err := loginChallengeCollection.InsertOne(ctx, models.LoginChallenge{
+		ID:             primitive.NewObjectID(),
+		Challenge_hash: hashUserToken(challengeId),
+		User_id:        userId,
+		Ip:             ip,
+		Device_id:      deviceId,
+		Created_at:     now,
+		Expires_at:     now.Add(loginChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return challengeId, nil
+}
+
+// UseLoginChallenge counts an attempt against the challenge and returns it.
+// A challenge survives only a handful of wrong codes.
+func UseLoginChallenge(challengeId string) (models.LoginChallenge, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var challenge models.LoginChallenge
+	err := loginChallengeCollection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"challenge_hash": hashUserToken(challengeId),
+			"attempts":       bson.M{"$lt": maxLoginChallengeAttempts},
+			"expires_at":     bson.M{"$gt": time.Now()},
+		},
+		bson.D{{"$inc", bson.D{{"attempts", 1}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&challenge)
+
+	if err == mongo.ErrNoDocuments {
+		return challenge, ErrInvalidLoginChallenge
+	}
+
+	return challenge, err
+}
+
+func DeleteLoginChallenge(challengeId string) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	_, err := loginChallengeCollection.DeleteOne(ctx, bson.M{"challenge_hash": hashUserToken(challengeId)})
+	return err
+}
+
+func ensureLoginChallengeIndexes(ctx context.Context) error {
+	_, err := loginChallengeCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{"challenge_hash", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"expires_at", 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
@@ -0,0 +1,58 @@
+package helper
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+
+	"restaurant-management/database"
+	"restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var deviceCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionDevices)
+
+// AuthenticateDevice looks up a registered, unrevoked device and checks the
+// secret it presented.
+func AuthenticateDevice(ctx context.Context, deviceId, secret string) (models.Device, bool) {
+	var device models.Device
+
+	if deviceId == "" || secret == "" {
+		return device, false
+	}
+
+	err := deviceCollection.FindOne(ctx, bson.M{"device_id": deviceId, "revoked": false}).Decode(&device)
+	if err != nil {
+		return device, false
+	}
+
+	match := subtle.ConstantTimeCompare([]byte(HashDeviceSecret(secret)), []byte(device.Secret_hash))
+	return device, match == 1
+}
+
+// CheckDeviceBinding makes a token issued through PIN login usable only from
+// the device it was issued to, and only while that device is still trusted.
+// Tokens without a device pass unchanged.
+func CheckDeviceBinding(ctx context.Context, claims *SignedDetails, deviceId, secret string) string {
+	if claims.Device_id == "" {
+		return ""
+	}
+
+	if deviceId != claims.Device_id {
+		return "token is bound to another device"
+	}
+
+	if _, ok := AuthenticateDevice(ctx, deviceId, secret); !ok {
+		return "unknown or revoked device"
+	}
+	return ""
+}
+
+// device secrets are long random values, so a plain SHA-256 is enough
+func HashDeviceSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
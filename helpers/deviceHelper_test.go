@@ -0,0 +1,65 @@
+package helper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCheckDeviceBindingWithoutLookup(t *testing.T) {
+	ctx := context.Background()
+
+	if msg := CheckDeviceBinding(ctx, &SignedDetails{}, "", ""); msg != "" {
+		t.Errorf("token without a device was rejected: %s", msg)
+	}
+
+	bound := &SignedDetails{Device_id: "till-1"}
+	if msg := CheckDeviceBinding(ctx, bound, "till-2", "secret"); msg == "" {
+		t.Error("token was accepted from another device")
+	}
+	if msg := CheckDeviceBinding(ctx, bound, "", ""); msg == "" {
+		t.Error("device bound token was accepted without device headers")
+	}
+}
+
+func TestCheckDeviceBinding(t *testing.T) {
+	collection := testDatabase(t).Collection("devices")
+	previous := deviceCollection
+	deviceCollection = collection
+	t.Cleanup(func() { deviceCollection = previous })
+
+	now := time.Now()
+	for _, device := range []models.Device{
+		{ID: primitive.NewObjectID(), Device_id: "till-1", Secret_hash: HashDeviceSecret("right-secret"), Created_at: now},
+		{ID: primitive.NewObjectID(), Device_id: "till-2", Secret_hash: HashDeviceSecret("right-secret"), Revoked: true, Revoked_at: &now, Created_at: now},
+	} {
+		if _, err := collection.InsertOne(context.Background(), device); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name     string
+		deviceId string
+		secret   string
+		wantOK   bool
+	}{
+		{"right secret", "till-1", "right-secret", true},
+		{"wrong secret", "till-1", "wrong-secret", false},
+		{"missing secret", "till-1", "", false},
+		{"revoked device", "till-2", "right-secret", false},
+		{"unknown device", "till-3", "right-secret", false},
+	}
+
+	for _, tc := range cases {
+		claims := &SignedDetails{Device_id: tc.deviceId}
+		msg := CheckDeviceBinding(context.Background(), claims, tc.deviceId, tc.secret)
+		if ok := msg == ""; ok != tc.wantOK {
+			t.Errorf("%s: got %q, want ok=%v", tc.name, msg, tc.wantOK)
+		}
+	}
+}
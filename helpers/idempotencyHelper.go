@@ -0,0 +1,141 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"restaurant-management/database"
+	"restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var idempotencyKeyCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionIdempotencyKeys)
+
+// how long a retry is answered from the stored response
+const IdempotencyRetention = 24 * time.Hour
+
+// how long a request may hold a key before a retry can take it over; longer
+// than any handler's timeout
+const idempotencyLease = 2 * time.Minute
+
+var ErrIdempotencyKeyTaken = errors.New("idempotency key already used")
+
+// ReserveIdempotencyKey claims the key for a request. If the key was already
+// claimed, ErrIdempotencyKeyTaken is returned together with the earlier
+// record so the caller can replay or reject. A key past its retention, or
+// one whose request stopped without finishing and whose lease ran out, is
+// taken over instead.
+func ReserveIdempotencyKey(key, userId, method, path, requestHash string) (*models.IdempotencyKey, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	record := models.IdempotencyKey{
+		ID:           primitive.NewObjectID(),
+		Key:          idempotencyScope(key, userId, method, path),
+		User_id:      userId,
+		Method:       method,
+		Path:         path,
+		Request_hash: requestHash,
+		Lease_id:     primitive.NewObjectID().Hex(),
+		Locked_until: now.Add(idempotencyLease),
+		Created_at:   now,
+		Expires_at:   now.Add(IdempotencyRetention),
+	}
+
+	_, err := idempotencyKeyCollection.InsertOne(ctx, record)
+	if err == nil {
+		return &record, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	var existing models.IdempotencyKey
+	if err := idempotencyKeyCollection.FindOne(ctx, bson.M{"key": record.Key}).Decode(&existing); err != nil {
+		return nil, err
+	}
+
+	// the TTL monitor runs about once a minute, so an expired key may still
+	// be there
+	expired := !existing.Expires_at.After(now)
+	abandoned := !existing.Completed && !existing.Locked_until.After(now) && existing.Request_hash == requestHash
+	if !expired && !abandoned {
+		return &existing, ErrIdempotencyKeyTaken
+	}
+
+	record.ID = existing.ID
+	result, err := idempotencyKeyCollection.ReplaceOne(ctx, leaseFilter(&existing), record)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 1 {
+		return &record, nil
+	}
+
+	// another retry took it over first
+	if err := idempotencyKeyCollection.FindOne(ctx, bson.M{"key": record.Key}).Decode(&existing); err != nil {
+		return nil, err
+	}
+	return &existing, ErrIdempotencyKeyTaken
+}
+
+// CompleteIdempotencyKey stores the response to replay for the key.
+func CompleteIdempotencyKey(record *models.IdempotencyKey, statusCode int, contentType string, response []byte) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	_, err := idempotencyKeyCollection.UpdateOne(
+		ctx,
+		leaseFilter(record),
+		bson.D{{"$set", bson.D{
+			{"completed", true},
+			{"status_code", statusCode},
+			{"content_type", contentType},
+			{"response", response},
+		}}},
+	)
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a key whose request failed on our side, so
+// the client may retry it.
+func ReleaseIdempotencyKey(record *models.IdempotencyKey) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	_, err := idempotencyKeyCollection.DeleteOne(ctx, leaseFilter(record))
+	return err
+}
+
+// leaseFilter matches the record only while its holder still has it, so a
+// request that was taken over cannot overwrite or drop its successor's key.
+func leaseFilter(record *models.IdempotencyKey) bson.M {
+	if record.Lease_id == "" {
+		// kept from before leases
+		return bson.M{"_id": record.ID, "lease_id": nil}
+	}
+	return bson.M{"_id": record.ID, "lease_id": record.Lease_id}
+}
+
+// keys are per user and endpoint, so two tablets generating the same key for
+// different requests cannot see each other's responses
+func idempotencyScope(key, userId, method, path string) string {
+	return userId + " " + method + " " + path + " " + key
+}
+
+func ensureIdempotencyKeyIndexes(ctx context.Context) error {
+	_, err := idempotencyKeyCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{"key", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"expires_at", 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
@@ -0,0 +1,30 @@
+package helper
+
+import (
+	"context"
+	"time"
+)
+
+// EnsureIndexes creates the lookup and TTL indexes the helpers rely on. TTL
+// indexes let MongoDB drop revoked tokens, sessions, attempt counters, old
+// kitchen events and idempotency keys once they have expired.
+func EnsureIndexes() error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	for _, ensure := range []func(context.Context) error{
+		ensureTokenIndexes,
+		ensureLoginAttemptIndexes,
+		ensureUserTokenIndexes,
+		ensureLoginChallengeIndexes,
+		ensureKitchenEventIndexes,
+		ensureIdempotencyKeyIndexes,
+	} {
+		if err := ensure(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,299 @@
+package helper
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tokens are signed with Ed25519 (EdDSA). Private keys live in JWT_KEY_DIR as
+// <kid>.pem files; the newest one signs and all of them verify. After a
+// rotation the older keys stay until every token they signed has expired.
+
+const kidTimeLayout = "20060102T150405.000Z"
+
+const (
+	// an unknown kid reloads the key directory at most this often, so
+	// tokens with made-up kids cannot keep the server reading files
+	keyReloadInterval = 10 * time.Second
+	// unknown kids remembered between reloads
+	maxKeyMisses = 1024
+)
+
+type signingKey struct {
+	kid        string
+	privateKey ed25519.PrivateKey
+	created    time.Time
+}
+
+type keyRing struct {
+	mu         sync.RWMutex
+	dir        string
+	loaded     bool
+	reloadedAt time.Time
+	keys       map[string]*signingKey
+	misses     map[string]time.Time
+	active     *signingKey
+}
+
+var jwtKeys = &keyRing{}
+
+func keyDir() string {
+	if dir := os.Getenv("JWT_KEY_DIR"); dir != "" {
+		return dir
+	}
+	return "keys"
+}
+
+// load reads every key from the key directory and creates the first one when
+// the directory is empty.
+func (r *keyRing) load() error {
+	r.dir = keyDir()
+	if err := os.MkdirAll(r.dir, 0700); err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(r.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]*signingKey{}
+	for _, file := range files {
+		key, err := readSigningKey(file)
+		if err != nil {
+			return err
+		}
+		keys[key.kid] = key
+	}
+
+	r.keys = keys
+	r.active = newestKey(keys)
+	r.loaded = true
+	r.reloadedAt = time.Now()
+	r.misses = map[string]time.Time{}
+
+	if r.active == nil {
+		_, err := r.rotate()
+		return err
+	}
+
+	return nil
+}
+
+func (r *keyRing) signer() (*signingKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.loaded {
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.active, nil
+}
+
+// verifier looks a key up by kid. An unknown kid triggers a reload, since
+// another instance sharing the directory may have rotated, but only once per
+// keyReloadInterval; until then a kid that was not found is rejected right
+// away, and so is a kid that missed within the interval.
+func (r *keyRing) verifier(kid string) (ed25519.PublicKey, error) {
+	r.mu.RLock()
+	key, ok := r.keys[kid]
+	settled := r.loaded && (time.Since(r.misses[kid]) < keyReloadInterval || time.Since(r.reloadedAt) < keyReloadInterval)
+	r.mu.RUnlock()
+
+	if !ok && !settled {
+		r.mu.Lock()
+		// another request may have reloaded while this one waited
+		key, ok = r.keys[kid]
+		if !ok && (!r.loaded || time.Since(r.reloadedAt) >= keyReloadInterval) {
+			if err := r.load(); err != nil {
+				r.mu.Unlock()
+				return nil, err
+			}
+			key, ok = r.keys[kid]
+		}
+		if !ok {
+			r.rememberMiss(kid)
+		}
+		r.mu.Unlock()
+	}
+
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+
+	return key.privateKey.Public().(ed25519.PublicKey), nil
+}
+
+// rememberMiss must be called with the lock held. Misses are forgotten on
+// the next reload, or all at once when there are too many of them.
+func (r *keyRing) rememberMiss(kid string) {
+	if len(r.misses) >= maxKeyMisses {
+		r.misses = map[string]time.Time{}
+	}
+	r.misses[kid] = time.Now()
+}
+
+// rotate must be called with the lock held.
+func (r *keyRing) rotate() (*signingKey, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	key := &signingKey{
+		kid:        now.Format(kidTimeLayout) + "-" + hex.EncodeToString(suffix),
+		privateKey: privateKey,
+		created:    now,
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(r.dir, key.kid+".pem"), data, 0600); err != nil {
+		return nil, err
+	}
+
+	r.keys[key.kid] = key
+	r.active = key
+	r.prune()
+
+	return key, nil
+}
+
+// prune drops keys that were retired longer ago than the longest token
+// lifetime. A key is retired when the next newer key was created.
+func (r *keyRing) prune() {
+	sorted := sortedKeys(r.keys)
+
+	for i := 0; i < len(sorted)-1; i++ {
+		retiredAt := sorted[i+1].created
+		if time.Since(retiredAt) > RefreshTokenTTL {
+			delete(r.keys, sorted[i].kid)
+			os.Remove(filepath.Join(r.dir, sorted[i].kid+".pem"))
+		}
+	}
+}
+
+// RotateSigningKey starts signing with a fresh key. Tokens signed with the
+// previous keys keep verifying until they expire.
+func RotateSigningKey() (string, error) {
+	jwtKeys.mu.Lock()
+	defer jwtKeys.mu.Unlock()
+
+	if !jwtKeys.loaded {
+		if err := jwtKeys.load(); err != nil {
+			return "", err
+		}
+	}
+
+	key, err := jwtKeys.rotate()
+	if err != nil {
+		return "", err
+	}
+
+	return key.kid, nil
+}
+
+// JWKS returns the public verification keys as a JSON Web Key Set.
+func JWKS() (map[string]interface{}, error) {
+	if _, err := jwtKeys.signer(); err != nil {
+		return nil, err
+	}
+
+	jwtKeys.mu.RLock()
+	defer jwtKeys.mu.RUnlock()
+
+	keys := []map[string]string{}
+	for _, key := range sortedKeys(jwtKeys.keys) {
+		publicKey := key.privateKey.Public().(ed25519.PublicKey)
+		keys = append(keys, map[string]string{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"alg": "EdDSA",
+			"use": "sig",
+			"kid": key.kid,
+			"x":   base64.RawURLEncoding.EncodeToString(publicKey),
+		})
+	}
+
+	return map[string]interface{}{"keys": keys}, nil
+}
+
+func readSigningKey(file string) (*signingKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid key file " + file)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("key file " + file + " is not an Ed25519 key")
+	}
+
+	kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+
+	created, err := time.Parse(kidTimeLayout, strings.SplitN(kid, "-", 2)[0])
+	if err != nil {
+		info, statErr := os.Stat(file)
+		if statErr != nil {
+			return nil, statErr
+		}
+		created = info.ModTime()
+	}
+
+	return &signingKey{kid: kid, privateKey: privateKey, created: created}, nil
+}
+
+func sortedKeys(keys map[string]*signingKey) []*signingKey {
+	sorted := make([]*signingKey, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, key)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].created.Before(sorted[j].created)
+	})
+
+	return sorted
+}
+
+func newestKey(keys map[string]*signingKey) *signingKey {
+	sorted := sortedKeys(keys)
+	if len(sorted) == 0 {
+		return nil
+	}
+	return sorted[len(sorted)-1]
+}
@@ -0,0 +1,134 @@
+package helper
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// useKeyRing gives the test its own key directory and an empty key ring.
+func useKeyRing(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("JWT_KEY_DIR", t.TempDir())
+	previous := jwtKeys
+	jwtKeys = &keyRing{}
+	t.Cleanup(func() { jwtKeys = previous })
+}
+
+// verifyWithJWKS checks a token the way an outside service would: only with
+// the public keys published in the JWKS.
+func verifyWithJWKS(t *testing.T, signedToken string) error {
+	t.Helper()
+
+	jwks, err := JWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = jwt.ParseWithClaims(
+		signedToken,
+		&SignedDetails{},
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			for _, key := range jwks["keys"].([]map[string]string) {
+				if key["kid"] != kid {
+					continue
+				}
+				x, err := base64.RawURLEncoding.DecodeString(key["x"])
+				if err != nil {
+					return nil, err
+				}
+				return ed25519.PublicKey(x), nil
+			}
+			return nil, jwt.ErrTokenUnverifiable
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+	)
+	return err
+}
+
+func TestSigningKeyRoundTrip(t *testing.T) {
+	useKeyRing(t)
+
+	token, _, err := GenerateAllTokens(SignedDetails{Uid: "user-1", Session_id: "session-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, msg := ValidateToken(token); msg != "" {
+		t.Fatalf("token signed with the active key did not validate: %s", msg)
+	}
+	if err := verifyWithJWKS(t, token); err != nil {
+		t.Fatalf("token did not verify against the JWKS: %v", err)
+	}
+
+	if _, err := RotateSigningKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the retired key is still within its grace window
+	if _, msg := ValidateToken(token); msg != "" {
+		t.Errorf("token signed with the retired key stopped validating: %s", msg)
+	}
+	if err := verifyWithJWKS(t, token); err != nil {
+		t.Errorf("retired key is missing from the JWKS: %v", err)
+	}
+
+	fresh, _, err := GenerateAllTokens(SignedDetails{Uid: "user-1", Session_id: "session-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyWithJWKS(t, fresh); err != nil {
+		t.Errorf("token signed with the new key did not verify against the JWKS: %v", err)
+	}
+}
+
+func TestRetiredKeysArePrunedAfterGraceWindow(t *testing.T) {
+	useKeyRing(t)
+
+	if _, err := jwtKeys.signer(); err != nil {
+		t.Fatal(err)
+	}
+
+	jwtKeys.mu.Lock()
+	old := jwtKeys.active
+	jwtKeys.mu.Unlock()
+
+	token, _, err := GenerateAllTokens(SignedDetails{Uid: "user-1", Session_id: "session-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pretend the old key was retired by a rotation longer ago than any
+	// token lifetime
+	_, successorKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwtKeys.mu.Lock()
+	old.created = time.Now().Add(-3 * RefreshTokenTTL)
+	jwtKeys.keys["successor"] = &signingKey{
+		kid:        "successor",
+		privateKey: successorKey,
+		created:    time.Now().Add(-2 * RefreshTokenTTL),
+	}
+	_, err = jwtKeys.rotate()
+	jwtKeys.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, msg := ValidateToken(token); msg == "" {
+		t.Error("token signed with a key past its grace window still validates")
+	}
+	if _, err := os.Stat(filepath.Join(jwtKeys.dir, old.kid+".pem")); !os.IsNotExist(err) {
+		t.Errorf("pruned key file is still on disk: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package helper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"restaurant-management/database"
+	"restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var kitchenEventCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionKitchenEvents)
+var counterCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionCounters)
+
+// the feed only has to cover a service plus reconnect slack
+const kitchenEventRetention = 24 * time.Hour
+
+// an event missing from the sequence this long after its successors were
+// written belongs to a publisher that failed, not to one still in flight
+const kitchenEventGapGrace = 2 * time.Second
+
+var kitchenListeners = struct {
+	sync.Mutex
+	channels map[chan struct{}]bool
+}{channels: map[chan struct{}]bool{}}
+
+// PublishKitchenEvent appends an event to the kitchen feed and wakes the
+// streams connected to this instance. Streams on other instances pick it up
+// on their next poll.
+func PublishKitchenEvent(eventType string, item models.OrderItem) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := counterCollection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": "kitchen_events"},
+		bson.D{{"$inc", bson.D{{"seq", int64(1)}}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return err
+	}
+
+	event := models.KitchenEvent{
+		ID:         primitive.NewObjectID(),
+		Seq:        counter.Seq,
+		Type:       eventType,
+		Order_id:   item.Order_id,
+		Item:       item,
+		Created_at: time.Now(),
+	}
+
+	if _, err := kitchenEventCollection.InsertOne(ctx, event); err != nil {
+		return err
+	}
+
+	notifyKitchenListeners()
+	return nil
+}
+
+// KitchenEventsAfter returns the events following seq, oldest first. It stops
+// at a gap in the sequence while the missing event may still be being
+// written, so a reader that resumes from the last returned seq never skips
+// one.
+func KitchenEventsAfter(seq int64, limit int64) ([]models.KitchenEvent, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	cursor, err := kitchenEventCollection.Find(
+		ctx,
+		bson.M{"seq": bson.M{"$gt": seq}},
+		options.Find().SetSort(bson.M{"seq": 1}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []models.KitchenEvent
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+
+	next := seq + 1
+	for i, event := range events {
+		if event.Seq != next && time.Since(event.Created_at) < kitchenEventGapGrace {
+			return events[:i], nil
+		}
+		next = event.Seq + 1
+	}
+
+	return events, nil
+}
+
+// LatestKitchenEventSeq is where a display without a Last-Event-ID starts.
+func LatestKitchenEventSeq() (int64, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var event models.KitchenEvent
+	err := kitchenEventCollection.FindOne(
+		ctx,
+		bson.M{},
+		options.FindOne().SetSort(bson.M{"seq": -1}),
+	).Decode(&event)
+
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return event.Seq, err
+}
+
+// ListenKitchenEvents registers a stream for wake-ups. The channel is
+// buffered by one and never closed; call the returned function to stop
+// listening.
+func ListenKitchenEvents() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	kitchenListeners.Lock()
+	kitchenListeners.channels[ch] = true
+	kitchenListeners.Unlock()
+
+	return ch, func() {
+		kitchenListeners.Lock()
+		delete(kitchenListeners.channels, ch)
+		kitchenListeners.Unlock()
+	}
+}
+
+func notifyKitchenListeners() {
+	kitchenListeners.Lock()
+	defer kitchenListeners.Unlock()
+
+	for ch := range kitchenListeners.channels {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func ensureKitchenEventIndexes(ctx context.Context) error {
+	_, err := kitchenEventCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{"seq", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"created_at", 1}}, Options: options.Index().SetExpireAfterSeconds(int32(kitchenEventRetention.Seconds()))},
+	})
+	return err
+}
@@ -0,0 +1,230 @@
+package helper
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"restaurant-management/database"
+	"restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var loginAttemptCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionLoginAttempts)
+var lockoutEventCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionLockoutEvents)
+
+// attemptPolicy decides how many failures a key gets for free, when the
+// exponential backoff turns into a full lockout, and how long that lasts.
+type attemptPolicy struct {
+	freeAttempts int
+	lockoutAt    int
+	lockout      time.Duration
+}
+
+var (
+	accountPolicy = attemptPolicy{freeAttempts: 3, lockoutAt: 10, lockout: 15 * time.Minute}
+
+	// a whole restaurant usually shares one address, so be more lenient
+	ipPolicy = attemptPolicy{freeAttempts: 20, lockoutAt: 100, lockout: 15 * time.Minute}
+
+	// a 4-6 digit PIN has little entropy, so guesses are cut off early
+	pinPolicy    = attemptPolicy{freeAttempts: 2, lockoutAt: 5, lockout: 15 * time.Minute}
+	devicePolicy = attemptPolicy{freeAttempts: 10, lockoutAt: 30, lockout: 15 * time.Minute}
+)
+
+const (
+	maxLoginBackoff    = 5 * time.Minute
+	loginAttemptWindow = time.Hour
+)
+
+func AccountAttemptKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func IPAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+func PinAttemptKey(deviceId, userId string) string {
+	return "pin:" + deviceId + ":" + userId
+}
+
+// ApprovalAttemptKey counts wrong PINs entered for a manager signing off a
+// void; it shares the PIN policy.
+func ApprovalAttemptKey(userId string) string {
+	return "pin:approval:" + userId
+}
+
+func DeviceAttemptKey(deviceId string) string {
+	return "device:" + deviceId
+}
+
+func policyFor(key string) attemptPolicy {
+	switch {
+	case strings.HasPrefix(key, "ip:"):
+		return ipPolicy
+	case strings.HasPrefix(key, "pin:"):
+		return pinPolicy
+	case strings.HasPrefix(key, "device:"):
+		return devicePolicy
+	}
+	return accountPolicy
+}
+
+// LoginBlockedFor reports how long the caller has to wait before any of the
+// given keys may attempt another login. Zero means go ahead.
+func LoginBlockedFor(keys ...string) (time.Duration, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	cursor, err := loginAttemptCollection.Find(ctx, bson.M{
+		"key":           bson.M{"$in": keys},
+		"blocked_until": bson.M{"$gt": now},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var attempts []models.LoginAttempt
+	if err := cursor.All(ctx, &attempts); err != nil {
+		return 0, err
+	}
+
+	var wait time.Duration
+	for _, attempt := range attempts {
+		if remaining := attempt.Blocked_until.Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+
+	return wait, nil
+}
+
+// RecordLoginFailure counts a failed attempt against every key. Past the free
+// attempts each failure doubles the wait; reaching the lockout threshold
+// locks the key and records a lockout event.
+func RecordLoginFailure(ip string, keys ...string) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	for _, key := range keys {
+		now := time.Now()
+		policy := policyFor(key)
+
+		var attempt models.LoginAttempt
+		err := loginAttemptCollection.FindOneAndUpdate(
+			ctx,
+			bson.M{"key": key},
+			bson.D{
+				{"$inc", bson.D{{"failures", 1}}},
+				{"$set", bson.D{
+					{"last_failure_at", now},
+					{"expires_at", now.Add(loginAttemptWindow)},
+				}},
+			},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&attempt)
+		if err != nil {
+			return err
+		}
+
+		if attempt.Failures <= policy.freeAttempts {
+			continue
+		}
+
+		blockedUntil := now.Add(backoff(attempt.Failures - policy.freeAttempts))
+		locked := attempt.Failures >= policy.lockoutAt
+		if locked {
+			blockedUntil = now.Add(policy.lockout)
+		}
+
+		_, err = loginAttemptCollection.UpdateOne(
+			ctx,
+			bson.M{"key": key},
+			bson.D{{"$set", bson.D{
+				{"blocked_until", blockedUntil},
+				{"expires_at", blockedUntil.Add(loginAttemptWindow)},
+			}}},
+		)
+		if err != nil {
+			return err
+		}
+
+		if !locked {
+			continue
+		}
+
+		event := models.LockoutEvent{
+			ID:           primitive.NewObjectID(),
+			Key:          key,
+			Ip:           ip,
+			Failures:     attempt.Failures,
+			Locked_until: blockedUntil,
+			Created_at:   now,
+		}
+		event.Event_id = event.ID.Hex()
+
+		if _, err := lockoutEventCollection.InsertOne(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ResetLoginFailures clears the counters, e.g. after a successful login or
+// when an admin unlocks an account.
+func ResetLoginFailures(keys ...string) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	_, err := loginAttemptCollection.DeleteMany(ctx, bson.M{"key": bson.M{"$in": keys}})
+	return err
+}
+
+func GetLockoutEvents(limit int64) ([]models.LockoutEvent, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	cursor, err := lockoutEventCollection.Find(
+		ctx,
+		bson.M{},
+		options.Find().SetSort(bson.D{{"created_at", -1}}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []models.LockoutEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func backoff(step int) time.Duration {
+	wait := time.Duration(math.Pow(2, float64(step-1))) * time.Second
+	if wait > maxLoginBackoff || wait <= 0 {
+		return maxLoginBackoff
+	}
+	return wait
+}
+
+func ensureLoginAttemptIndexes(ctx context.Context) error {
+	_, err := loginAttemptCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{"key", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"expires_at", 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
@@ -0,0 +1,120 @@
+package helper
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"restaurant-management/database"
+	"restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var orderItemCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionOrderItems)
+
+// RunMigrations brings documents written by older versions up to the current
+// models. Every migration only matches documents still in the old shape, so
+// running them again is a no-op.
+func RunMigrations() error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	for _, migrate := range []func(context.Context) error{
+		migrateOrderItemQuantities,
+		migrateUserRoles,
+		migrateEmailVerified,
+	} {
+		if err := migrate(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Order items used to store their portion size (S, M or L) in quantity and
+// always meant a single portion. The size moves to its own field and the
+// quantity becomes a count.
+func migrateOrderItemQuantities(ctx context.Context) error {
+	result, err := orderItemCollection.UpdateMany(
+		ctx,
+		bson.M{"quantity": bson.M{"$type": "string"}},
+		mongo.Pipeline{
+			{{"$set", bson.D{
+				{"size", "$quantity"},
+				{"quantity", 1},
+			}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.ModifiedCount > 0 {
+		log.Println("migrated quantity of", result.ModifiedCount, "order items")
+	}
+	return nil
+}
+
+// Users created before roles have none and would be turned away by every
+// role check. Like the first signup, the oldest of them becomes ADMIN when
+// there is no admin yet; everyone else starts as WAITER.
+func migrateUserRoles(ctx context.Context) error {
+	admins, err := userCollection.CountDocuments(ctx, bson.M{"role": models.RoleAdmin})
+	if err != nil {
+		return err
+	}
+
+	if admins == 0 {
+		var oldest models.User
+		err := userCollection.FindOneAndUpdate(
+			ctx,
+			bson.M{"role": nil},
+			bson.D{{"$set", bson.D{{"role", models.RoleAdmin}}}},
+			options.FindOneAndUpdate().SetSort(bson.D{{"created_at", 1}, {"_id", 1}}),
+		).Decode(&oldest)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+		if err == nil {
+			log.Println("made the oldest user", oldest.User_id, "admin")
+		}
+	}
+
+	result, err := userCollection.UpdateMany(
+		ctx,
+		bson.M{"role": nil},
+		bson.D{{"$set", bson.D{{"role", models.RoleWaiter}}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.ModifiedCount > 0 {
+		log.Println("gave", result.ModifiedCount, "users without a role the WAITER role")
+	}
+	return nil
+}
+
+// Accounts created before email verification never received a link, so
+// they would be locked out of every route that requires a verified address.
+// They are trusted as verified; new signups always store the field.
+func migrateEmailVerified(ctx context.Context) error {
+	result, err := userCollection.UpdateMany(
+		ctx,
+		bson.M{"email_verified": bson.M{"$exists": false}},
+		bson.D{{"$set", bson.D{{"email_verified", true}}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.ModifiedCount > 0 {
+		log.Println("marked", result.ModifiedCount, "existing users as email verified")
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package helper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// useUserCollection points the migrations at a scratch users collection.
+func useUserCollection(t *testing.T) *mongo.Collection {
+	t.Helper()
+
+	collection := testDatabase(t).Collection("user")
+	previous := userCollection
+	userCollection = collection
+	t.Cleanup(func() { userCollection = previous })
+	return collection
+}
+
+func roleOf(t *testing.T, collection *mongo.Collection, userId string) string {
+	t.Helper()
+
+	var user models.User
+	if err := collection.FindOne(context.Background(), bson.M{"user_id": userId}).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Role == nil {
+		return ""
+	}
+	return *user.Role
+}
+
+func TestMigrateUserRolesPromotesOldestWhenNoAdmin(t *testing.T) {
+	collection := useUserCollection(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := collection.InsertMany(ctx, []interface{}{
+		bson.M{"user_id": "newer", "created_at": now},
+		bson.M{"user_id": "oldest", "created_at": now.Add(-time.Hour)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateUserRoles(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := roleOf(t, collection, "oldest"); got != models.RoleAdmin {
+		t.Errorf("oldest user: got %q, want %q", got, models.RoleAdmin)
+	}
+	if got := roleOf(t, collection, "newer"); got != models.RoleWaiter {
+		t.Errorf("newer user: got %q, want %q", got, models.RoleWaiter)
+	}
+}
+
+func TestMigrateUserRolesKeepsExistingRoles(t *testing.T) {
+	collection := useUserCollection(t)
+	ctx := context.Background()
+
+	_, err := collection.InsertMany(ctx, []interface{}{
+		bson.M{"user_id": "admin", "role": models.RoleAdmin, "created_at": time.Now()},
+		bson.M{"user_id": "manager", "role": models.RoleManager, "created_at": time.Now()},
+		bson.M{"user_id": "legacy", "created_at": time.Now().Add(-time.Hour)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateUserRoles(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"admin":   models.RoleAdmin,
+		"manager": models.RoleManager,
+		"legacy":  models.RoleWaiter,
+	}
+	for userId, role := range want {
+		if got := roleOf(t, collection, userId); got != role {
+			t.Errorf("%s: got %q, want %q", userId, got, role)
+		}
+	}
+
+	// a second run has nothing left to change
+	if err := migrateUserRoles(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := roleOf(t, collection, "legacy"); got != models.RoleWaiter {
+		t.Errorf("rerun changed legacy user to %q", got)
+	}
+}
+
+func TestMigrateEmailVerified(t *testing.T) {
+	collection := useUserCollection(t)
+	ctx := context.Background()
+
+	_, err := collection.InsertMany(ctx, []interface{}{
+		bson.M{"user_id": "legacy"},
+		bson.M{"user_id": "pending", "email_verified": false},
+		bson.M{"user_id": "verified", "email_verified": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateEmailVerified(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		"legacy":   true,
+		"pending":  false,
+		"verified": true,
+	}
+	for userId, verified := range want {
+		var user models.User
+		if err := collection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+			t.Fatal(err)
+		}
+		if user.Email_verified != verified {
+			t.Errorf("%s: email_verified is %v, want %v", userId, user.Email_verified, verified)
+		}
+	}
+}
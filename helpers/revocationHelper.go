@@ -0,0 +1,84 @@
+package helper
+
+import (
+	"context"
+	"time"
+
+	"restaurant-management/database"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var revokedTokenCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionRevokedTokens)
+
+// RevokeToken puts the token's ID (jti) on the deny list. The entry only has
+// to outlive the token itself, so it expires together with it.
+func RevokeToken(signedToken string) error {
+
+	if signedToken == "" {
+		return nil
+	}
+
+	claims := &SignedDetails{}
+	if _, _, err := jwt.NewParser().ParseUnverified(signedToken, claims); err != nil {
+		return err
+	}
+
+	if claims.ID == "" {
+		return nil
+	}
+
+	expiresAt := tokenExpiry(signedToken)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	_, err := revokedTokenCollection.UpdateOne(
+		ctx,
+		bson.M{"jti": claims.ID},
+		bson.D{{"$setOnInsert", bson.D{
+			{"jti", claims.ID},
+			{"user_id", claims.Uid},
+			{"revoked_at", time.Now()},
+			{"expires_at", expiresAt},
+		}}},
+		options.Update().SetUpsert(true),
+	)
+
+	return err
+}
+
+func IsTokenRevoked(jti string) (bool, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	count, err := revokedTokenCollection.CountDocuments(ctx, bson.M{"jti": jti}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func ensureTokenIndexes(ctx context.Context) error {
+	_, err := revokedTokenCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{"jti", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"expires_at", 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = sessionCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{"session_id", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"user_id", 1}}},
+		{Keys: bson.D{{"device_id", 1}}},
+		{Keys: bson.D{{"expires_at", 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+
+	return err
+}
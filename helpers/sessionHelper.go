@@ -0,0 +1,164 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"restaurant-management/database"
+	"restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var sessionCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionSessions)
+
+var (
+	ErrSessionRevoked     = errors.New("session has been revoked")
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected, session revoked")
+)
+
+// StartSession records a new token family for the user. deviceId is empty
+// unless the session was opened on a registered terminal.
+func StartSession(sessionId, userId, deviceId, token, refreshToken string) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	session := models.Session{
+		ID:            primitive.NewObjectID(),
+		Session_id:    sessionId,
+		User_id:       userId,
+		Device_id:     deviceId,
+		Token:         token,
+		Refresh_token: refreshToken,
+		Created_at:    now,
+		Updated_at:    now,
+		Expires_at:    tokenExpiry(refreshToken),
+	}
+
+	_, err := sessionCollection.InsertOne(ctx, session)
+	return err
+}
+
+// RotateSession swaps the session's tokens for a fresh pair, provided the
+// presented refresh token is the current one. A stale refresh token means it
+// was already rotated and is being replayed, so the family is revoked.
+func RotateSession(sessionId, presentedRefreshToken, token, refreshToken string) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var previous models.Session
+	err := sessionCollection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"session_id":    sessionId,
+			"refresh_token": presentedRefreshToken,
+			"revoked":       false,
+		},
+		bson.D{{"$set", bson.D{
+			{"token", token},
+			{"refresh_token", refreshToken},
+			{"updated_at", now},
+			{"expires_at", tokenExpiry(refreshToken)},
+		}}},
+	).Decode(&previous)
+
+	if err == nil {
+		// the access token issued alongside the rotated refresh token is
+		// superseded as well
+		return RevokeToken(previous.Token)
+	}
+
+	if err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	var session models.Session
+	if err := sessionCollection.FindOne(ctx, bson.M{"session_id": sessionId}).Decode(&session); err != nil {
+		return ErrSessionRevoked
+	}
+
+	if session.Revoked {
+		return ErrSessionRevoked
+	}
+
+	if err := RevokeSession(sessionId); err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
+
+// RevokeSession kills a token family so none of its refresh tokens can be
+// exchanged again, and denies the tokens it currently holds.
+func RevokeSession(sessionId string) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var session models.Session
+	err := sessionCollection.FindOneAndUpdate(
+		ctx,
+		bson.M{"session_id": sessionId, "revoked": false},
+		bson.D{{"$set", bson.D{
+			{"revoked", true},
+			{"revoked_at", now},
+			{"updated_at", now},
+		}}},
+	).Decode(&session)
+
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := RevokeToken(session.Token); err != nil {
+		return err
+	}
+
+	return RevokeToken(session.Refresh_token)
+}
+
+// RevokeAllSessions signs the user out everywhere.
+func RevokeAllSessions(userId string) (int, error) {
+	return revokeSessionsWhere(bson.M{"user_id": userId})
+}
+
+// RevokeDeviceSessions signs everyone out of a terminal.
+func RevokeDeviceSessions(deviceId string) (int, error) {
+	return revokeSessionsWhere(bson.M{"device_id": deviceId})
+}
+
+func revokeSessionsWhere(filter bson.M) (int, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	filter["revoked"] = false
+	cursor, err := sessionCollection.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var sessions []models.Session
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return 0, err
+	}
+
+	for _, session := range sessions {
+		if err := RevokeSession(session.Session_id); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(sessions), nil
+}
@@ -2,61 +2,87 @@ package helper
 
 import (
 	"context"
-	"errors"
-	"os"
 	"time"
 
 	"restaurant-management/database"
 
 	"github.com/golang-jwt/jwt/v4"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+
+	AccessTokenTTL  = 24 * time.Hour
+	RefreshTokenTTL = 7 * 24 * time.Hour
+
+	// sessions opened with a PIN on a shared terminal are kept short
+	DeviceAccessTokenTTL  = 15 * time.Minute
+	DeviceRefreshTokenTTL = 12 * time.Hour
+
+	DeviceIdHeader     = "X-Device-Id"
+	DeviceSecretHeader = "X-Device-Secret"
+)
+
 type SignedDetails struct {
-	Email      string `json:"email"`
-	First_name string `json:"first_name"`
-	Last_name  string `json:"last_name"`
-	Uid        string `json:"uid"`
+	Email               string `json:"email"`
+	First_name          string `json:"first_name"`
+	Last_name           string `json:"last_name"`
+	Uid                 string `json:"uid"`
+	Role                string `json:"role"`
+	Email_verified      bool   `json:"email_verified"`
+	Totp_setup_required bool   `json:"totp_setup_required,omitempty"`
+	Session_id          string `json:"sid"`
+	Device_id           string `json:"device_id,omitempty"`
+	Token_type          string `json:"token_type"`
 	jwt.RegisteredClaims
 }
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
+var userCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionUsers)
 
-var SECRET_KEY = os.Getenv("SECRET_KEY")
+// GenerateAllTokens signs an access/refresh pair for the user described by
+// details. Both tokens get their own ID and belong to details.Session_id;
+// tokens bound to a device get the shorter device lifetimes.
+func GenerateAllTokens(details SignedDetails) (string, string, error) {
 
-func GenerateAllTokens(email, firstName, lastName, uid string) (string, string, error) {
+	key, err := jwtKeys.signer()
+	if err != nil {
+		return "", "", err
+	}
 
-	if SECRET_KEY == "" {
-		return "", "", errors.New("SECRET_KEY not set")
+	accessTTL, refreshTTL := AccessTokenTTL, RefreshTokenTTL
+	if details.Device_id != "" {
+		accessTTL, refreshTTL = DeviceAccessTokenTTL, DeviceRefreshTokenTTL
 	}
 
-	claims := SignedDetails{
-		Email:      email,
-		First_name: firstName,
-		Last_name:  lastName,
-		Uid:        uid,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-		},
+	claims := details
+	claims.Token_type = TokenTypeAccess
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        primitive.NewObjectID().Hex(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTTL)),
 	}
 
 	refreshClaims := SignedDetails{
-		Uid: uid,
+		Uid:        details.Uid,
+		Session_id: details.Session_id,
+		Device_id:  details.Device_id,
+		Token_type: TokenTypeRefresh,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
+			ID:        primitive.NewObjectID().Hex(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(refreshTTL)),
 		},
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).
-		SignedString([]byte(SECRET_KEY))
+	token, err := signToken(key, claims)
 	if err != nil {
 		return "", "", err
 	}
 
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).
-		SignedString([]byte(SECRET_KEY))
+	refreshToken, err := signToken(key, refreshClaims)
 	if err != nil {
 		return "", "", err
 	}
@@ -64,6 +90,12 @@ func GenerateAllTokens(email, firstName, lastName, uid string) (string, string,
 	return token, refreshToken, nil
 }
 
+func signToken(key *signingKey, claims SignedDetails) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.privateKey)
+}
+
 func UpdateAllTokens(token, refreshToken, userId string) error {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
@@ -88,16 +120,14 @@ func UpdateAllTokens(token, refreshToken, userId string) error {
 
 func ValidateToken(signedToken string) (*SignedDetails, string) {
 
-	if SECRET_KEY == "" {
-		return nil, "server configuration error"
-	}
-
 	token, err := jwt.ParseWithClaims(
 		signedToken,
 		&SignedDetails{},
 		func(token *jwt.Token) (interface{}, error) {
-			return []byte(SECRET_KEY), nil
+			kid, _ := token.Header["kid"].(string)
+			return jwtKeys.verifier(kid)
 		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
 	)
 
 	if err != nil {
@@ -111,3 +141,13 @@ func ValidateToken(signedToken string) (*SignedDetails, string) {
 
 	return claims, ""
 }
+
+// tokenExpiry reads the expiry of a token we signed ourselves, falling back
+// to the longest lifetime when it cannot be read.
+func tokenExpiry(signedToken string) time.Time {
+	claims := &SignedDetails{}
+	if _, _, err := jwt.NewParser().ParseUnverified(signedToken, claims); err != nil || claims.ExpiresAt == nil {
+		return time.Now().Add(RefreshTokenTTL)
+	}
+	return claims.ExpiresAt.Time
+}
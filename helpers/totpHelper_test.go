@@ -0,0 +1,82 @@
+package helper
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 Appendix B uses the ASCII seed below with HMAC-SHA1 and eight
+// digits; six digit codes are the last six digits of the same values.
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPMatchesRFC6238Vectors(t *testing.T) {
+	for _, tc := range rfc6238Vectors {
+		at := time.Unix(tc.unix, 0)
+
+		step, ok := ValidateTOTP(rfc6238Secret, tc.code, at)
+		if !ok {
+			t.Errorf("T=%d: code %s was rejected", tc.unix, tc.code)
+			continue
+		}
+		if want := tc.unix / totpPeriod; step != want {
+			t.Errorf("T=%d: matched step %d, want %d", tc.unix, step, want)
+		}
+	}
+}
+
+func TestTOTPSkewWindow(t *testing.T) {
+	at := time.Unix(1234567890, 0)
+	current := at.Unix() / totpPeriod
+	key, _ := totpEncoding.DecodeString(rfc6238Secret)
+
+	cases := []struct {
+		name   string
+		offset int64
+		wantOK bool
+	}{
+		{"two steps behind", -2, false},
+		{"one step behind", -1, true},
+		{"current step", 0, true},
+		{"one step ahead", 1, true},
+		{"two steps ahead", 2, false},
+	}
+
+	for _, tc := range cases {
+		code := totpCode(key, current+tc.offset)
+
+		step, ok := ValidateTOTP(rfc6238Secret, code, at)
+		if ok != tc.wantOK {
+			t.Errorf("%s: got ok=%v, want %v", tc.name, ok, tc.wantOK)
+		}
+		if ok && step != current+tc.offset {
+			t.Errorf("%s: matched step %d, want %d", tc.name, step, current+tc.offset)
+		}
+	}
+}
+
+func TestTOTPRejectsMalformedInput(t *testing.T) {
+	at := time.Unix(59, 0)
+
+	for name, input := range map[string][2]string{
+		"short code":     {rfc6238Secret, "28708"},
+		"long code":      {rfc6238Secret, "2870820"},
+		"invalid secret": {"not base32!", "287082"},
+		"wrong code":     {rfc6238Secret, "000000"},
+	} {
+		if _, ok := ValidateTOTP(input[0], input[1], at); ok {
+			t.Errorf("%s was accepted", name)
+		}
+	}
+}
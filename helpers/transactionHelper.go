@@ -0,0 +1,65 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+
+	"restaurant-management/database"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// IllegalOperation, returned when a transaction is started on a standalone
+// server
+const errCodeIllegalOperation = 20
+
+// set once the server turned out not to support transactions, so later
+// writes skip straight to the fallback
+var transactionsUnsupported atomic.Bool
+
+// RunAtomic runs write inside a multi-document transaction, retrying it the
+// way the driver does for transient errors. MongoDB only has transactions on
+// replica sets and sharded clusters; on a standalone server write runs
+// without one and, if it fails part way, undo is called to remove what it
+// already wrote. Both get a context that must be passed to every operation.
+func RunAtomic(ctx context.Context, write func(context.Context) error, undo func(context.Context) error) error {
+
+	if !transactionsUnsupported.Load() {
+		err := runInTransaction(ctx, write)
+		if !isTransactionUnsupported(err) {
+			return err
+		}
+
+		transactionsUnsupported.Store(true)
+		log.Println("transactions are not supported by this server, falling back to compensating writes")
+	}
+
+	if err := write(ctx); err != nil {
+		if undoErr := undo(ctx); undoErr != nil {
+			log.Println("could not undo a failed write:", undoErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func runInTransaction(ctx context.Context, write func(context.Context) error) error {
+	session, err := database.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, write(sessionCtx)
+	})
+	return err
+}
+
+func isTransactionUnsupported(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorCode(errCodeIllegalOperation)
+}
@@ -0,0 +1,173 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"restaurant-management/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	pingOnce sync.Once
+	pingErr  error
+)
+
+// testDatabase returns a scratch database on the configured server, or skips
+// the test when no mongod is reachable.
+func testDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	pingOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		pingErr = database.Client.Ping(ctx, nil)
+	})
+	if pingErr != nil {
+		t.Skip("no mongod reachable:", pingErr)
+	}
+
+	db := database.Client.Database("restaurant_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		db.Drop(context.Background())
+	})
+	return db
+}
+
+// useFallback forces RunAtomic onto the compensating path, as if the server
+// had answered with error code 20.
+func useFallback(t *testing.T) {
+	t.Helper()
+
+	previous := transactionsUnsupported.Load()
+	transactionsUnsupported.Store(true)
+	t.Cleanup(func() { transactionsUnsupported.Store(previous) })
+}
+
+func TestIsTransactionUnsupported(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"illegal operation", mongo.CommandError{Code: errCodeIllegalOperation}, true},
+		{"wrapped", errors.Join(errors.New("start"), mongo.CommandError{Code: errCodeIllegalOperation}), true},
+		{"other server error", mongo.CommandError{Code: 11000}, false},
+		{"plain error", errors.New("boom"), false},
+		{"no error", nil, false},
+	}
+
+	for _, tc := range cases {
+		if got := isTransactionUnsupported(tc.err); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRunAtomicFallbackUndoesPartialWrite(t *testing.T) {
+	useFallback(t)
+
+	written := []string{}
+	failure := errors.New("insert many failed")
+
+	err := RunAtomic(
+		context.Background(),
+		func(ctx context.Context) error {
+			written = append(written, "order")
+			return failure
+		},
+		func(ctx context.Context) error {
+			written = written[:0]
+			return nil
+		},
+	)
+
+	if err != failure {
+		t.Fatalf("got %v, want the write's error", err)
+	}
+	if len(written) != 0 {
+		t.Fatalf("undo did not run, left %v", written)
+	}
+}
+
+func TestRunAtomicFallbackKeepsSuccessfulWrite(t *testing.T) {
+	useFallback(t)
+
+	undone := false
+	err := RunAtomic(
+		context.Background(),
+		func(ctx context.Context) error { return nil },
+		func(ctx context.Context) error {
+			undone = true
+			return nil
+		},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if undone {
+		t.Fatal("undo ran after a successful write")
+	}
+}
+
+// The order is inserted, then the item insert fails on a duplicate key; no
+// order may be left behind, with or without transactions.
+func TestRunAtomicLeavesNoOrphanOrder(t *testing.T) {
+	db := testDatabase(t)
+	orders := db.Collection(database.CollectionOrders)
+	items := db.Collection(database.CollectionOrderItems)
+
+	// on a standalone server the first run switches to the fallback itself
+	previous := transactionsUnsupported.Load()
+	t.Cleanup(func() { transactionsUnsupported.Store(previous) })
+
+	for _, fallback := range []bool{false, true} {
+		if fallback {
+			useFallback(t)
+		}
+
+		orderId := primitive.NewObjectID().Hex()
+		itemId := primitive.NewObjectID()
+
+		err := RunAtomic(
+			context.Background(),
+			func(ctx context.Context) error {
+				if _, err := orders.InsertOne(ctx, bson.M{"order_id": orderId}); err != nil {
+					return err
+				}
+				_, err := items.InsertMany(ctx, []interface{}{
+					bson.M{"_id": itemId, "order_id": orderId},
+					bson.M{"_id": itemId, "order_id": orderId},
+				})
+				return err
+			},
+			func(ctx context.Context) error {
+				if _, err := items.DeleteMany(ctx, bson.M{"order_id": orderId}); err != nil {
+					return err
+				}
+				_, err := orders.DeleteOne(ctx, bson.M{"order_id": orderId})
+				return err
+			},
+		)
+		if err == nil {
+			t.Fatalf("fallback=%v: duplicate item insert did not fail", fallback)
+		}
+
+		for name, collection := range map[string]*mongo.Collection{"orders": orders, "order items": items} {
+			count, err := collection.CountDocuments(context.Background(), bson.M{"order_id": orderId})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 0 {
+				t.Errorf("fallback=%v: %d %s left behind", fallback, count, name)
+			}
+		}
+	}
+}
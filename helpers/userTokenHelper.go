@@ -0,0 +1,102 @@
+package helper
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"restaurant-management/database"
+	"restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var userTokenCollection *mongo.Collection = database.OpenCollection(database.Client, database.CollectionUserTokens)
+
+var ErrInvalidUserToken = errors.New("token is invalid, expired or already used")
+
+// CreateUserToken issues a new token for the purpose and drops any earlier
+// unused one, so only the latest email works.
+func CreateUserToken(userId, purpose string, ttl time.Duration) (string, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	_, err := userTokenCollection.DeleteMany(ctx, bson.M{
+		"user_id": userId,
+		"purpose": purpose,
+		"used_at": nil,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	_, err = userTokenCollection.InsertOne(ctx, models.UserToken{
+		ID:         primitive.NewObjectID(),
+		Token_hash: hashUserToken(token),
+		User_id:    userId,
+		Purpose:    purpose,
+		Created_at: now,
+		Expires_at: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ConsumeUserToken marks the token used and returns the user it was issued to.
+func ConsumeUserToken(token, purpose string) (string, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var userToken models.UserToken
+	err := userTokenCollection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"token_hash": hashUserToken(token),
+			"purpose":    purpose,
+			"used_at":    nil,
+			"expires_at": bson.M{"$gt": now},
+		},
+		bson.D{{"$set", bson.D{{"used_at", now}}}},
+	).Decode(&userToken)
+
+	if err == mongo.ErrNoDocuments {
+		return "", ErrInvalidUserToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return userToken.User_id, nil
+}
+
+func hashUserToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func ensureUserTokenIndexes(ctx context.Context) error {
+	_, err := userTokenCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{"token_hash", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"expires_at", 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
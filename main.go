@@ -1,17 +1,16 @@
 package main
 
 import (
+	"log"
 	"os"
-	"restaurant-management/database"
-	"restaurant-management/middleware"
+	"strings"
+
+	helper "restaurant-management/helpers"
 	"restaurant-management/routes"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var foodCollection *mongo.Collection = database.OpenCollection(database.Client , "food")
-
 func main() {
 	port := os.Getenv("PORT")
 
@@ -19,18 +18,37 @@ func main() {
 		port = "8080"
 	}
 
+	if err := helper.EnsureIndexes(); err != nil {
+		log.Println("could not create indexes:", err)
+	}
+
+	if err := helper.RunMigrations(); err != nil {
+		log.Println("could not migrate data:", err)
+	}
+
 	router := gin.New()
 	router.Use(gin.Logger())
-	router.Use(middleware.Authentication())
 
-	routes.FoodRoutes(router)
-	routes.InvoiceRoutes(router)
-	routes.MenuRoutes(router)
-	routes.OrderItemRoutes(router)
-	routes.OrderRoutes(router)
-	routes.TableRoutes(router)
-	routes.UserRoutes(router)
+	// login throttling keys on the client address, so X-Forwarded-For is only
+	// believed when it comes from one of our own proxies
+	if err := router.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatal("invalid TRUSTED_PROXIES: ", err)
+	}
+
+	routes.Register(router)
 
 	router.Run(":" + port)
 
-}
\ No newline at end of file
+}
+
+// trustedProxies reads the comma-separated TRUSTED_PROXIES. Without it no
+// proxy is trusted and the client address is the connection's.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerifiedEmail keeps accounts that have not confirmed their email
+// address out of everything but their own account routes.
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("email_verified") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "confirm your email address to continue"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireTwoFactorEnrollment holds back users whose role must use two-factor
+// authentication until they have enrolled.
+func RequireTwoFactorEnrollment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("totp_setup_required") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "your role requires two-factor authentication, enroll to continue"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -35,10 +35,41 @@ func Authentication() gin.HandlerFunc {
 			return
 		}
 
+		if claims.Token_type != helper.TokenTypeAccess {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "access token required"})
+			c.Abort()
+			return
+		}
+
+		deviceId := c.GetHeader(helper.DeviceIdHeader)
+		secret := c.GetHeader(helper.DeviceSecretHeader)
+		if errMsg := helper.CheckDeviceBinding(c.Request.Context(), claims, deviceId, secret); errMsg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": errMsg})
+			c.Abort()
+			return
+		}
+
+		revoked, err := helper.IsTokenRevoked(claims.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not check token status"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			c.Abort()
+			return
+		}
+
 		c.Set("email", claims.Email)
 		c.Set("first_name", claims.First_name)
 		c.Set("last_name", claims.Last_name)
 		c.Set("uid", claims.Uid)
+		c.Set("role", claims.Role)
+		c.Set("email_verified", claims.Email_verified)
+		c.Set("totp_setup_required", claims.Totp_setup_required)
+		c.Set("session_id", claims.Session_id)
+		c.Set("device_id", claims.Device_id)
 
 		c.Next()
 	}
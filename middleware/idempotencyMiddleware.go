@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	helper "restaurant-management/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Idempotency makes a create endpoint safe to retry. A request carrying an
+// Idempotency-Key header runs once; repeating it within the retention window
+// replays the stored response, and reusing the key for a different request
+// is rejected with 409. Requests without the header are unaffected. It must
+// run after Authentication.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "could not read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(sum[:])
+
+		record, err := helper.ReserveIdempotencyKey(key, c.GetString("uid"), c.Request.Method, c.Request.URL.Path, requestHash)
+		if err == helper.ErrIdempotencyKeyTaken {
+			switch {
+			case record.Request_hash != requestHash:
+				c.JSON(http.StatusConflict, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			case !record.Completed:
+				c.JSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still being processed"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(record.Status_code, record.Content_type, record.Response)
+			}
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not check Idempotency-Key"})
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// a panicking handler never finished, so the client may retry
+		defer func() {
+			if r := recover(); r != nil {
+				helper.ReleaseIdempotencyKey(record)
+				panic(r)
+			}
+		}()
+
+		c.Next()
+
+		// server errors are not final, the client should be able to retry
+		if recorder.Status() >= http.StatusInternalServerError {
+			helper.ReleaseIdempotencyKey(record)
+			return
+		}
+
+		helper.CompleteIdempotencyKey(record, recorder.Status(), recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+	}
+}
+
+// responseRecorder keeps a copy of the response body as it is written.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRoles only lets the request through when the authenticated user
+// holds one of the given roles. It must run after Authentication.
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+
+		if !hasRole(role, roles) {
+			c.JSON(http.StatusForbidden, gin.H{"error": forbiddenMessage(role)})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSelfOrRoles lets users act on their own record (identified by the
+// given route parameter) and otherwise falls back to the role check.
+func RequireSelfOrRoles(param string, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+
+		if c.GetString("uid") != c.Param(param) && !hasRole(role, roles) {
+			c.JSON(http.StatusForbidden, gin.H{"error": forbiddenMessage(role)})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func hasRole(role string, roles []string) bool {
+	for _, r := range roles {
+		if role == r {
+			return true
+		}
+	}
+	return false
+}
+
+func forbiddenMessage(role string) string {
+	if role == "" {
+		return "no role assigned to this account"
+	}
+	return "role " + role + " is not allowed to access this resource"
+}
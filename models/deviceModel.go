@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Device is a shared POS terminal enrolled by an admin. Staff sign in on it
+// with their PIN; the device proves itself with the secret handed out at
+// enrollment, of which only a hash is kept.
+type Device struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Device_id    string             `json:"device_id"`
+	Name         *string            `json:"name" validate:"required,min=2,max=100"`
+	Secret_hash  string             `json:"-"`
+	Created_by   string             `json:"created_by"`
+	Revoked      bool               `json:"revoked"`
+	Revoked_at   *time.Time         `json:"revoked_at"`
+	Last_used_at *time.Time         `json:"last_used_at"`
+	Created_at   time.Time          `json:"created_at"`
+	Updated_at   time.Time          `json:"updated_at"`
+}
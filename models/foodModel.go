@@ -6,13 +6,61 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// FoodSize is a portion a food can be ordered in, with its own price.
+type FoodSize struct {
+	Size  string   `json:"size" validate:"required,max=20"`
+	Price *float64 `json:"price" validate:"required,gt=0"`
+}
+
+// ModifierGroup is a choice offered with a food, such as the doneness of a
+// steak or optional add-ons. Guests pick at least Min_select and, unless
+// Max_select is 0, at most Max_select of its options.
+type ModifierGroup struct {
+	Group_id   string           `json:"group_id"`
+	Name       string           `json:"name" validate:"required,max=50"`
+	Min_select int              `json:"min_select" validate:"min=0"`
+	Max_select int              `json:"max_select" validate:"min=0"`
+	Options    []ModifierOption `json:"options" validate:"required,min=1,dive"`
+}
+
+// ModifierOption is one pick in a group. Price_delta is added to the item's
+// price and may be negative.
+type ModifierOption struct {
+	Option_id   string  `json:"option_id"`
+	Name        string  `json:"name" validate:"required,max=50"`
+	Price_delta float64 `json:"price_delta"`
+}
+
+// Price is the food's base price, charged when it has no sizes. A food with
+// sizes must be ordered in one of them.
 type Food struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Name       *string            `json:"name" validate:"required,min=2,max=100"`
-	Price      *float64           `json:"price" validate:"required"`
-	Food_image *string            `json:"food_image" validate:"required"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Food_id    string             `json:"food_id"`
-	Menu_id    *string            `json:"menu_id" validate:"required"`
-}
\ No newline at end of file
+	ID              primitive.ObjectID `bson:"_id"`
+	Name            *string            `json:"name" validate:"required,min=2,max=100"`
+	Price           *float64           `json:"price" validate:"required"`
+	Sizes           []FoodSize         `json:"sizes" validate:"dive"`
+	Modifier_groups []ModifierGroup    `json:"modifier_groups" validate:"dive"`
+	Food_image      *string            `json:"food_image" validate:"required"`
+	Created_at      time.Time          `json:"created_at"`
+	Updated_at      time.Time          `json:"updated_at"`
+	Food_id         string             `json:"food_id"`
+	Menu_id         *string            `json:"menu_id" validate:"required"`
+	Station_id      *string            `json:"station_id"`
+}
+
+// SizePrice returns the price of the given size, or the plain price when no
+// size is asked for and the food has none.
+func (food Food) SizePrice(size string) (float64, bool) {
+	if size == "" {
+		if len(food.Sizes) > 0 || food.Price == nil {
+			return 0, false
+		}
+		return *food.Price, true
+	}
+
+	for _, s := range food.Sizes {
+		if s.Size == size && s.Price != nil {
+			return *s.Price, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IdempotencyKey remembers the outcome of a create request so a client that
+// retries with the same Idempotency-Key gets the same answer instead of a
+// second record. Response is empty while the first request is still running.
+// The running request holds the key until Locked_until; if it dies without
+// finishing, a retry may take the key over after that. Lease_id tells the
+// holder apart from whoever took over.
+type IdempotencyKey struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Key          string             `json:"key"`
+	User_id      string             `json:"user_id"`
+	Method       string             `json:"method"`
+	Path         string             `json:"path"`
+	Request_hash string             `json:"request_hash"`
+	Completed    bool               `json:"completed"`
+	Status_code  int                `json:"status_code"`
+	Content_type string             `json:"content_type"`
+	Response     []byte             `json:"response"`
+	Lease_id     string             `json:"lease_id"`
+	Locked_until time.Time          `json:"locked_until"`
+	Created_at   time.Time          `json:"created_at"`
+	Expires_at   time.Time          `json:"expires_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	KitchenEventItemCreated   = "order_item.created"
+	KitchenEventItemUpdated   = "order_item.updated"
+	KitchenEventItemCancelled = "order_item.cancelled"
+	KitchenEventItemBumped    = "order_item.bumped"
+	KitchenEventItemRecalled  = "order_item.recalled"
+	KitchenEventItemFired     = "order_item.fired"
+	KitchenEventItemMoved     = "order_item.moved"
+)
+
+// KitchenEvent is one entry of the kitchen display feed. Seq increases by one
+// per event, so a display that reconnects asks for everything after the last
+// seq it saw (its Last-Event-ID).
+type KitchenEvent struct {
+	ID         primitive.ObjectID `bson:"_id" json:"-"`
+	Seq        int64              `json:"seq"`
+	Type       string             `json:"type"`
+	Order_id   string             `json:"order_id"`
+	Item       OrderItem          `json:"item"`
+	Created_at time.Time          `json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginAttempt counts consecutive failed logins for one key, which is either
+// an account ("account:<email>") or a source address ("ip:<addr>").
+type LoginAttempt struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Key             string             `json:"key"`
+	Failures        int                `json:"failures"`
+	Last_failure_at time.Time          `json:"last_failure_at"`
+	Blocked_until   time.Time          `json:"blocked_until"`
+	Expires_at      time.Time          `json:"expires_at"`
+}
+
+type LockoutEvent struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Event_id     string             `json:"event_id"`
+	Key          string             `json:"key"`
+	Ip           string             `json:"ip"`
+	Failures     int                `json:"failures"`
+	Locked_until time.Time          `json:"locked_until"`
+	Created_at   time.Time          `json:"created_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginChallenge is the half-finished login of a user with two-factor
+// authentication: the password or PIN was right, the TOTP code is still
+// missing. Device_id is set when the login started on a shared terminal.
+type LoginChallenge struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Challenge_hash string             `json:"challenge_hash"`
+	User_id        string             `json:"user_id"`
+	Ip             string             `json:"ip"`
+	Device_id      string             `json:"device_id"`
+	Attempts       int                `json:"attempts"`
+	Created_at     time.Time          `json:"created_at"`
+	Expires_at     time.Time          `json:"expires_at"`
+}
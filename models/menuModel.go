@@ -7,12 +7,13 @@ import (
 )
 
 type Menu struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Name       string             `json:"name" bson:"name" validate:"required"`
-	Category   string             `json:"category" bson:"category" validate:"required"`
-	Start_Date *time.Time         `json:"start_date" bson:"start_date"`
-	End_Date   *time.Time         `json:"end_date" bson:"end_date"`
-	Created_at time.Time          `json:"created_at" bson:"created_at"`
-	Updated_at time.Time          `json:"updated_at" bson:"updated_at"`
-	Menu_id    string             `json:"menu_id" bson:"menu_id"`
+	ID                 primitive.ObjectID `bson:"_id"`
+	Name               string             `json:"name" bson:"name" validate:"required"`
+	Category           string             `json:"category" bson:"category" validate:"required"`
+	Start_Date         *time.Time         `json:"start_date" bson:"start_date"`
+	End_Date           *time.Time         `json:"end_date" bson:"end_date"`
+	Created_at         time.Time          `json:"created_at" bson:"created_at"`
+	Updated_at         time.Time          `json:"updated_at" bson:"updated_at"`
+	Menu_id            string             `json:"menu_id" bson:"menu_id"`
+	Default_station_id *string            `json:"default_station_id" bson:"default_station_id"`
 }
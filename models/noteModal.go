@@ -6,11 +6,25 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// What a note can be attached to. Customers have no record of their own yet,
+// so their notes are keyed by whatever identifies the guest (usually a phone
+// number). An order carrying the same value in customer_id shows them.
+const (
+	NoteSubjectOrder     = "ORDER"
+	NoteSubjectOrderItem = "ORDER_ITEM"
+	NoteSubjectTable     = "TABLE"
+	NoteSubjectCustomer  = "CUSTOMER"
+)
+
 type Note struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Text       string             `json:"text"`
-	Title      string             `json:"title"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Note_id    string             `json:"note_id"`
-}
\ No newline at end of file
+	ID           primitive.ObjectID `bson:"_id"`
+	Text         string             `json:"text" validate:"required,max=1000"`
+	Title        string             `json:"title" validate:"max=100"`
+	Subject_type *string            `json:"subject_type" validate:"required,eq=ORDER|eq=ORDER_ITEM|eq=TABLE|eq=CUSTOMER"`
+	Subject_id   *string            `json:"subject_id" validate:"required"`
+	Author_id    string             `json:"author_id"`
+	Author_name  string             `json:"author_name"`
+	Created_at   time.Time          `json:"created_at"`
+	Updated_at   time.Time          `json:"updated_at"`
+	Note_id      string             `json:"note_id"`
+}
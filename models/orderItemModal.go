@@ -6,13 +6,102 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Items are grouped into courses. A held item stays out of the kitchen until
+// its course is fired.
+//
+// Where an item stands in the kitchen. Bumping an item marks it READY; a
+// recall puts it back on the screen. Items without a prep status predate the
+// kitchen display and count as QUEUED.
+const (
+	PrepStatusQueued    = "QUEUED"
+	PrepStatusPreparing = "PREPARING"
+	PrepStatusReady     = "READY"
+	PrepStatusCancelled = "CANCELLED"
+)
+
+// Why an item was voided or an order cancelled. OTHER needs a note.
+const (
+	VoidReasonGuestRequest = "GUEST_REQUEST"
+	VoidReasonWrongItem    = "WRONG_ITEM"
+	VoidReasonKitchenError = "KITCHEN_ERROR"
+	VoidReasonQuality      = "QUALITY"
+	VoidReasonDuplicate    = "DUPLICATE"
+	VoidReasonOther        = "OTHER"
+)
+
 type OrderItem struct {
-	ID            primitive.ObjectID `bson:"_id"`
-	Quantity      *string            `json:"quantity" validate:"required,eq=S|eq=M|eq=L"`
-	Unit_price    *float64           `json:"unit_price" validate:"required"`
-	Created_at    time.Time          `json:"created_at"`
-	Updated_at    time.Time          `json:"updated_at"`
-	Food_id       *string            `json:"food_id" validate:"required"`
-	Order_item_id string             `json:"order_item_id"`
-	Order_id      string             `json:"order_id" validate:"required"`
-}
\ No newline at end of file
+	ID                primitive.ObjectID `bson:"_id"`
+	Quantity          *int               `json:"quantity" validate:"required,min=1,max=99"`
+	Size              *string            `json:"size"`
+	Modifiers         []SelectedModifier `json:"modifiers"`
+	Unit_price        *float64           `json:"unit_price"`
+	Created_at        time.Time          `json:"created_at"`
+	Updated_at        time.Time          `json:"updated_at"`
+	Food_id           *string            `json:"food_id" validate:"required"`
+	Food_name         *string            `json:"food_name"`
+	List_price        *float64           `json:"list_price"`
+	Price_override_by string             `json:"price_override_by,omitempty"`
+	Order_item_id     string             `json:"order_item_id"`
+	Order_id          string             `json:"order_id" validate:"required"`
+	Station_id        string             `json:"station_id"`
+	Course            *int               `json:"course" validate:"omitempty,min=1,max=9"`
+	Held              bool               `json:"held"`
+	Fired_at          *time.Time         `json:"fired_at"`
+	Prep_status       *string            `json:"prep_status"`
+	Bumped_at         *time.Time         `json:"bumped_at"`
+	Void              *OrderItemVoid     `json:"void"`
+}
+
+// SelectedModifier is an option picked for an item. The client sends the
+// group and option ids; names and price are snapshotted from the food.
+type SelectedModifier struct {
+	Group_id    string  `json:"group_id"`
+	Group_name  string  `json:"group_name"`
+	Option_id   string  `json:"option_id"`
+	Option_name string  `json:"option_name"`
+	Price_delta float64 `json:"price_delta"`
+}
+
+// OrderItemVoid records why and by whom an item was taken off an order. The
+// item stays in the order for reporting but is no longer billed. Approved_by
+// is the manager who signed off a void of an item already in the kitchen.
+type OrderItemVoid struct {
+	Reason      string    `json:"reason"`
+	Note        string    `json:"note"`
+	Voided_by   string    `json:"voided_by"`
+	Approved_by string    `json:"approved_by"`
+	Voided_at   time.Time `json:"voided_at"`
+}
+
+// SentToKitchen reports whether the kitchen may already be working on the
+// item. Only items held for a later course have not been sent.
+func (item OrderItem) SentToKitchen() bool {
+	return !item.Held
+}
+
+// CurrentCourse returns the item's course, treating a missing one as the
+// first course.
+func (item OrderItem) CurrentCourse() int {
+	if item.Course == nil {
+		return 1
+	}
+	return *item.Course
+}
+
+// CurrentQuantity returns the item's quantity, treating a missing one as one
+// portion.
+func (item OrderItem) CurrentQuantity() int {
+	if item.Quantity == nil {
+		return 1
+	}
+	return *item.Quantity
+}
+
+// CurrentPrepStatus returns the item's prep status, treating a missing one as
+// QUEUED.
+func (item OrderItem) CurrentPrepStatus() string {
+	if item.Prep_status == nil || *item.Prep_status == "" {
+		return PrepStatusQueued
+	}
+	return *item.Prep_status
+}
@@ -6,11 +6,108 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// An order moves PLACED → ACCEPTED → IN_PREPARATION → READY → SERVED → CLOSED
+// and can be CANCELLED until it has been served. An order that was merged
+// into another one ends up MERGED, which no transition leads to. An order
+// without a status predates the lifecycle and counts as PLACED.
+const (
+	OrderStatusPlaced        = "PLACED"
+	OrderStatusAccepted      = "ACCEPTED"
+	OrderStatusInPreparation = "IN_PREPARATION"
+	OrderStatusReady         = "READY"
+	OrderStatusServed        = "SERVED"
+	OrderStatusClosed        = "CLOSED"
+	OrderStatusCancelled     = "CANCELLED"
+	OrderStatusMerged        = "MERGED"
+)
+
+// FinishedOrderStatuses are the statuses an order never leaves. A finished
+// order no longer occupies its table.
+var FinishedOrderStatuses = []string{OrderStatusClosed, OrderStatusCancelled, OrderStatusMerged}
+
+// An order or some of its items change table through a move: a TRANSFER
+// takes the whole order, a SPLIT takes selected items into a new order and a
+// MERGE folds one order into another.
+const (
+	OrderMoveTransfer = "TRANSFER"
+	OrderMoveSplit    = "SPLIT"
+	OrderMoveMerge    = "MERGE"
+)
+
+var orderTransitions = map[string][]string{
+	OrderStatusPlaced:        {OrderStatusAccepted, OrderStatusCancelled},
+	OrderStatusAccepted:      {OrderStatusInPreparation, OrderStatusCancelled},
+	OrderStatusInPreparation: {OrderStatusReady, OrderStatusCancelled},
+	OrderStatusReady:         {OrderStatusServed, OrderStatusCancelled},
+	OrderStatusServed:        {OrderStatusClosed},
+}
+
+// CanTransitionOrder reports whether an order may move from one status to
+// the other.
+func CanTransitionOrder(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOrderStatuses lists where an order can go from the given status.
+func NextOrderStatuses(from string) []string {
+	return append([]string{}, orderTransitions[from]...)
+}
+
 type Order struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Order_Date time.Time          `json:"order_date" validate:"required"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Order_id   string             `json:"order_id"`
-	Table_id   *string            `json:"table_id" validate:"required"`
-}
\ No newline at end of file
+	ID             primitive.ObjectID  `bson:"_id"`
+	Order_Date     time.Time           `json:"order_date" validate:"required"`
+	Created_at     time.Time           `json:"created_at"`
+	Updated_at     time.Time           `json:"updated_at"`
+	Order_id       string              `json:"order_id"`
+	Table_id       *string             `json:"table_id" validate:"required"`
+	Customer_id    *string             `json:"customer_id"`
+	Status         *string             `json:"status"`
+	Status_history []OrderStatusChange `json:"status_history"`
+	Merged_into    *string             `json:"merged_into"`
+	Moves          []OrderMove         `json:"moves"`
+}
+
+// OrderStatusChange records who moved an order into a status and when.
+type OrderStatusChange struct {
+	From       string    `json:"from"`
+	Status     string    `json:"status"`
+	Reason     string    `json:"reason,omitempty"`
+	Changed_by string    `json:"changed_by"`
+	Changed_at time.Time `json:"changed_at"`
+}
+
+// OrderMove records items changing table or order. It is kept on every order
+// involved, so both sides can tell where items came from or went.
+type OrderMove struct {
+	Kind           string    `json:"kind"`
+	From_order_id  string    `json:"from_order_id"`
+	To_order_id    string    `json:"to_order_id"`
+	From_table_id  *string   `json:"from_table_id"`
+	To_table_id    *string   `json:"to_table_id"`
+	Order_item_ids []string  `json:"order_item_ids,omitempty"`
+	Moved_by       string    `json:"moved_by"`
+	Moved_at       time.Time `json:"moved_at"`
+}
+
+// CurrentStatus returns the order's status, treating a missing one as PLACED.
+func (order Order) CurrentStatus() string {
+	if order.Status == nil || *order.Status == "" {
+		return OrderStatusPlaced
+	}
+	return *order.Status
+}
+
+// IsOpen reports whether the order still occupies its table.
+func (order Order) IsOpen() bool {
+	for _, status := range FinishedOrderStatuses {
+		if order.CurrentStatus() == status {
+			return false
+		}
+	}
+	return true
+}
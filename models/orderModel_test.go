@@ -0,0 +1,75 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+var allOrderStatuses = []string{
+	OrderStatusPlaced,
+	OrderStatusAccepted,
+	OrderStatusInPreparation,
+	OrderStatusReady,
+	OrderStatusServed,
+	OrderStatusClosed,
+	OrderStatusCancelled,
+	OrderStatusMerged,
+}
+
+func TestOrderTransitions(t *testing.T) {
+	cases := []struct {
+		from string
+		next []string
+	}{
+		{OrderStatusPlaced, []string{OrderStatusAccepted, OrderStatusCancelled}},
+		{OrderStatusAccepted, []string{OrderStatusInPreparation, OrderStatusCancelled}},
+		{OrderStatusInPreparation, []string{OrderStatusReady, OrderStatusCancelled}},
+		{OrderStatusReady, []string{OrderStatusServed, OrderStatusCancelled}},
+		{OrderStatusServed, []string{OrderStatusClosed}},
+		{OrderStatusClosed, []string{}},
+		{OrderStatusCancelled, []string{}},
+		{OrderStatusMerged, []string{}},
+		{"UNKNOWN", []string{}},
+	}
+
+	for _, tc := range cases {
+		if got := NextOrderStatuses(tc.from); !reflect.DeepEqual(got, tc.next) {
+			t.Errorf("NextOrderStatuses(%s) = %v, want %v", tc.from, got, tc.next)
+		}
+
+		allowed := map[string]bool{}
+		for _, to := range tc.next {
+			allowed[to] = true
+		}
+		for _, to := range allOrderStatuses {
+			if got := CanTransitionOrder(tc.from, to); got != allowed[to] {
+				t.Errorf("CanTransitionOrder(%s, %s) = %v, want %v", tc.from, to, got, allowed[to])
+			}
+		}
+	}
+}
+
+func TestNoTransitionLeadsToMerged(t *testing.T) {
+	for _, from := range allOrderStatuses {
+		if CanTransitionOrder(from, OrderStatusMerged) {
+			t.Errorf("%s can transition to MERGED; merges have their own flow", from)
+		}
+	}
+}
+
+func TestFinishedOrdersCannotMove(t *testing.T) {
+	for _, status := range FinishedOrderStatuses {
+		if next := NextOrderStatuses(status); len(next) != 0 {
+			t.Errorf("finished status %s can still move to %v", status, next)
+		}
+	}
+}
+
+func TestNextOrderStatusesReturnsACopy(t *testing.T) {
+	next := NextOrderStatuses(OrderStatusPlaced)
+	next[0] = OrderStatusClosed
+
+	if !CanTransitionOrder(OrderStatusPlaced, OrderStatusAccepted) || CanTransitionOrder(OrderStatusPlaced, OrderStatusClosed) {
+		t.Error("changing the returned slice changed the transition map")
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is one login's token family. Every refresh rotates the tokens
+// stored here; presenting an older refresh token revokes the whole family.
+type Session struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Session_id    string             `json:"session_id"`
+	User_id       string             `json:"user_id"`
+	Device_id     string             `json:"device_id"`
+	Token         string             `json:"token"`
+	Refresh_token string             `json:"refresh_token"`
+	Revoked       bool               `json:"revoked"`
+	Revoked_at    *time.Time         `json:"revoked_at"`
+	Created_at    time.Time          `json:"created_at"`
+	Updated_at    time.Time          `json:"updated_at"`
+	Expires_at    time.Time          `json:"expires_at"`
+}
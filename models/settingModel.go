@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+const (
+	SettingTwoFactorPolicy = "two_factor_policy"
+
+	// written once by the signup that became the first admin
+	SettingAdminBootstrap = "admin_bootstrap"
+)
+
+// TwoFactorPolicy lists the roles that must use two-factor authentication.
+type TwoFactorPolicy struct {
+	Key            string    `json:"-"`
+	Required_roles []string  `json:"required_roles" validate:"dive,eq=ADMIN|eq=MANAGER|eq=WAITER|eq=CASHIER|eq=KITCHEN"`
+	Updated_by     string    `json:"updated_by"`
+	Updated_at     time.Time `json:"updated_at"`
+}
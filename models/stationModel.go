@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Station is a kitchen section with its own ticket queue, such as the grill
+// or the bar. Foods are routed to a station directly or through their menu's
+// default.
+type Station struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Name       *string            `json:"name" validate:"required,min=2,max=50"`
+	Created_at time.Time          `json:"created_at"`
+	Updated_at time.Time          `json:"updated_at"`
+	Station_id string             `json:"station_id"`
+}
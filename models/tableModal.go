@@ -13,4 +13,6 @@ type Table struct {
 	Created_at       time.Time          `json:"created_at"`
 	Updated_at       time.Time          `json:"updated_at"`
 	Table_id         string             `json:"table_id"`
-}
\ No newline at end of file
+	Occupied         bool               `json:"occupied"`
+	Open_orders      int                `json:"open_orders"`
+}
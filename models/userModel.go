@@ -1,22 +1,74 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	RoleAdmin   = "ADMIN"
+	RoleManager = "MANAGER"
+	RoleWaiter  = "WAITER"
+	RoleCashier = "CASHIER"
+	RoleKitchen = "KITCHEN"
+
+	// a user without a status predates account statuses and is active
+	UserStatusActive      = "ACTIVE"
+	UserStatusPending     = "PENDING"
+	UserStatusDeactivated = "DEACTIVATED"
+)
+
+var roleRanks = map[string]int{
+	RoleAdmin:   3,
+	RoleManager: 2,
+	RoleWaiter:  1,
+	RoleCashier: 1,
+	RoleKitchen: 1,
+}
+
+// RoleRank orders roles by how much they may do. Floor staff share a rank;
+// a user without a role ranks below everyone.
+func RoleRank(role string) int {
+	return roleRanks[role]
+}
+
 type User struct {
-	ID            primitive.ObjectID `bson:"_id"`
-	First_name    *string            `json:"first_name" validate:"required,min=2,max=100"`
-	Last_name     *string            `json:"last_name" validate:"required,min=2,max=100"`
-	Password      *string            `json:"Password" validate:"required,min=6"`
-	Email         *string            `json:"email" validate:"email,required"`
-	Avatar        *string            `json:"avatar"`
-	Phone         *string            `json:"phone" validate:"required"`
-	Token         *string            `json:"token"`
-	Refresh_Token *string            `json:"refresh_token"`
-	Created_at    time.Time          `json:"created_at"`
-	Updated_at    time.Time          `json:"updated_at"`
-	User_id       string             `json:"user_id"`
-}
\ No newline at end of file
+	ID             primitive.ObjectID `bson:"_id"`
+	First_name     *string            `json:"first_name" validate:"required,min=2,max=100"`
+	Last_name      *string            `json:"last_name" validate:"required,min=2,max=100"`
+	Password       *string            `json:"Password" validate:"required,min=6"`
+	Email          *string            `json:"email" validate:"email,required"`
+	Email_verified bool               `json:"email_verified"`
+	Avatar         *string            `json:"avatar"`
+	Phone          *string            `json:"phone" validate:"required"`
+	Role           *string            `json:"role" validate:"omitempty,eq=ADMIN|eq=MANAGER|eq=WAITER|eq=CASHIER|eq=KITCHEN"`
+	Status         *string            `json:"status"`
+	Token          *string            `json:"token"`
+	Refresh_Token  *string            `json:"refresh_token"`
+	Pin_hash       *string            `json:"-"`
+	Totp_secret    *string            `json:"-"`
+	Totp_enabled   bool               `json:"totp_enabled"`
+	Totp_last_step int64              `json:"-"`
+	Recovery_codes []string           `json:"-"`
+	Last_login_at  *time.Time         `json:"last_login_at"`
+	Created_at     time.Time          `json:"created_at"`
+	Updated_at     time.Time          `json:"updated_at"`
+	User_id        string             `json:"user_id"`
+}
+
+// MarshalJSON blanks the password hash and tokens so they cannot leave the
+// server even if a handler serializes a User directly. Handlers should still
+// respond with one of the user views.
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	safe := user(u)
+	safe.Password = nil
+	safe.Token = nil
+	safe.Refresh_Token = nil
+	safe.Pin_hash = nil
+	safe.Totp_secret = nil
+	safe.Recovery_codes = nil
+	return json.Marshal(safe)
+}
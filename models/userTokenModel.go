@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	TokenPurposePasswordReset = "PASSWORD_RESET"
+	TokenPurposeEmailVerify   = "EMAIL_VERIFY"
+	TokenPurposeInvitation    = "INVITATION"
+)
+
+// UserToken is a single-use, time-limited token mailed to a user. Only its
+// SHA-256 hash is stored.
+type UserToken struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Token_hash string             `json:"token_hash"`
+	User_id    string             `json:"user_id"`
+	Purpose    string             `json:"purpose"`
+	Used_at    *time.Time         `json:"used_at"`
+	Created_at time.Time          `json:"created_at"`
+	Expires_at time.Time          `json:"expires_at"`
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"restaurant-management/controllers"
+	"restaurant-management/middleware"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AdminRoutes(public, protected *gin.RouterGroup) {
+	protected.GET("/admin/two-factor-policy", middleware.RequireRoles(models.RoleAdmin), controllers.GetTwoFactorPolicy())
+	protected.PUT("/admin/two-factor-policy", middleware.RequireRoles(models.RoleAdmin), controllers.UpdateTwoFactorPolicy())
+	protected.GET("/admin/integrity", middleware.RequireRoles(models.RoleAdmin), controllers.GetIntegrityReport())
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"restaurant-management/controllers"
+	"restaurant-management/middleware"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func DeviceRoutes(public, protected *gin.RouterGroup) {
+	protected.GET("/devices", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), controllers.GetDevices())
+	protected.POST("/devices", middleware.RequireRoles(models.RoleAdmin), controllers.CreateDevice())
+	protected.POST("/devices/:device_id/revoke", middleware.RequireRoles(models.RoleAdmin), controllers.RevokeDevice())
+	protected.POST("/users/:user_id/pin", middleware.RequireSelfOrRoles("user_id", models.RoleAdmin), controllers.SetPin())
+	public.POST("/users/pin-login", controllers.PinLogin())
+}
@@ -1,13 +1,16 @@
 package routes
 
 import (
-	"github.com/gin-gonic/gin"
 	"restaurant-management/controllers"
+	"restaurant-management/middleware"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
 )
 
-func FoodRoutes(router *gin.Engine) {
-	router.GET("/foods" , controllers.GetFoods())
-	router.GET("/foods/:food_id" , controllers.GetFoodById())
-	router.POST("/foods" , controllers.CreateFood())
-	router.PATCH("/foods/:food_id", controllers.UpdateFood())
-}
\ No newline at end of file
+func FoodRoutes(public, protected *gin.RouterGroup) {
+	public.GET("/foods", controllers.GetFoods())
+	public.GET("/foods/:food_id", controllers.GetFoodById())
+	protected.POST("/foods", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), middleware.Idempotency(), controllers.CreateFood())
+	protected.PATCH("/foods/:food_id", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), controllers.UpdateFood())
+}
@@ -1,12 +1,16 @@
 package routes
 
 import (
-	"github.com/gin-gonic/gin"
 	"restaurant-management/controllers"
+	"restaurant-management/middleware"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
 )
-func InvoiceRoutes(router *gin.Engine) {
-	router.GET("/invoices" , controllers.GetInvoices())
-	router.GET("/invoices/:invoice_id", controllers.GetInvoiceById())
-	router.POST("/invoices" , controllers.CreateInvoice())
-	router.PATCH("/invoices/:invoice_id" , controllers.UpdateInvoice())
-}
\ No newline at end of file
+
+func InvoiceRoutes(public, protected *gin.RouterGroup) {
+	protected.GET("/invoices", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleCashier), controllers.GetInvoices())
+	protected.GET("/invoices/:invoice_id", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleCashier, models.RoleWaiter), controllers.GetInvoiceById())
+	protected.POST("/invoices", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleCashier, models.RoleWaiter), middleware.Idempotency(), controllers.CreateInvoice())
+	protected.PATCH("/invoices/:invoice_id", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleCashier), middleware.Idempotency(), controllers.UpdateInvoice())
+}
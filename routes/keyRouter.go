@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"restaurant-management/controllers"
+	"restaurant-management/middleware"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func KeyRoutes(public, protected *gin.RouterGroup) {
+	public.GET("/.well-known/jwks.json", controllers.GetJWKS())
+	protected.POST("/admin/keys/rotate", middleware.RequireRoles(models.RoleAdmin), controllers.RotateSigningKey())
+}
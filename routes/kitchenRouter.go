@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"restaurant-management/controllers"
+	"restaurant-management/middleware"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func KitchenRoutes(public, protected *gin.RouterGroup) {
+	protected.GET("/kitchen/stream", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleKitchen), controllers.StreamKitchen())
+	protected.GET("/kitchen/items", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleKitchen), controllers.GetKitchenQueue())
+	protected.POST("/kitchen/items/:order_item_id/start", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleKitchen), controllers.StartOrderItem())
+	protected.POST("/kitchen/items/:order_item_id/bump", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleKitchen), controllers.BumpOrderItem())
+	protected.POST("/kitchen/items/:order_item_id/recall", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleKitchen), controllers.RecallOrderItem())
+	protected.GET("/kitchen/tickets/:order_id", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleWaiter, models.RoleKitchen), controllers.GetKitchenTicket())
+}
@@ -1,12 +1,16 @@
 package routes
 
 import (
-	"github.com/gin-gonic/gin"
 	"restaurant-management/controllers"
+	"restaurant-management/middleware"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
 )
-func MenuRoutes(router *gin.Engine) {
-	router.GET("/menus" , controllers.GetMenus())
-	router.GET("/menus/:menu_id", controllers.GetMenuById())
-	router.POST("/menus", controllers.CreateMenu())
-	router.PATCH("/menus/:menu_id", controllers.UpdateMenu())
-} 
\ No newline at end of file
+
+func MenuRoutes(public, protected *gin.RouterGroup) {
+	public.GET("/menus", controllers.GetMenus())
+	public.GET("/menus/:menu_id", controllers.GetMenuById())
+	protected.POST("/menus", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), middleware.Idempotency(), controllers.CreateMenu())
+	protected.PATCH("/menus/:menu_id", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), controllers.UpdateMenu())
+}
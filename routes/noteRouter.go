@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NoteRoutes(public, protected *gin.RouterGroup) {
+	protected.GET("/notes", controllers.GetNotes())
+	protected.GET("/notes/:note_id", controllers.GetNoteById())
+	protected.POST("/notes", middleware.Idempotency(), controllers.CreateNote())
+	protected.PATCH("/notes/:note_id", controllers.UpdateNote())
+	protected.DELETE("/notes/:note_id", controllers.DeleteNote())
+}
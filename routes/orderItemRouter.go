@@ -1,13 +1,18 @@
 package routes
 
 import (
-	"github.com/gin-gonic/gin"
 	"restaurant-management/controllers"
+	"restaurant-management/middleware"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
 )
-func OrderItemRoutes(router *gin.Engine) {
-	router.GET("/orderItems" , controllers.GetOrderItems())
-	router.GET("/orderItems/:orderItem_id", controllers.GetOrderItemById())
-	router.POST("/orderItems", controllers.CreateOrderItem())
-	router.PATCH("/orderItems/:orderItem_id", controllers.UpdateOrderItem())
-	router.GET("/orderItems-order/:orderId",controllers.GetOrderItemsByOrder())
-}
\ No newline at end of file
+
+func OrderItemRoutes(public, protected *gin.RouterGroup) {
+	protected.GET("/orderItems", controllers.GetOrderItems())
+	protected.GET("/orderItems/:orderItem_id", controllers.GetOrderItemById())
+	protected.POST("/orderItems", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleWaiter), middleware.Idempotency(), controllers.CreateOrderItem())
+	protected.PATCH("/orderItems/:orderItem_id", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleWaiter, models.RoleKitchen), controllers.UpdateOrderItem())
+	protected.POST("/orderItems/:orderItem_id/void", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleWaiter, models.RoleCashier), controllers.VoidOrderItem())
+	protected.GET("/orderItems-order/:orderId", controllers.GetOrderItemsByOrder())
+}
@@ -1,12 +1,21 @@
 package routes
 
 import (
-	"github.com/gin-gonic/gin"
 	"restaurant-management/controllers"
+	"restaurant-management/middleware"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
 )
-func OrderRoutes(router *gin.Engine) {
-	router.GET("/orders" , controllers.GetOrders())
-	router.GET("/orders/:order_id", controllers.GetOrderById())
-	router.POST("/orders", controllers.CreateOrder())
-	router.PATCH("/orders/:order_id", controllers.UpdateOrder())
-}
\ No newline at end of file
+
+func OrderRoutes(public, protected *gin.RouterGroup) {
+	protected.GET("/orders", controllers.GetOrders())
+	protected.GET("/orders/:order_id", controllers.GetOrderById())
+	protected.POST("/orders", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleWaiter), middleware.Idempotency(), controllers.CreateOrder())
+	protected.PATCH("/orders/:order_id", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleWaiter), controllers.UpdateOrder())
+	protected.POST("/orders/:order_id/transitions", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleWaiter, models.RoleKitchen, models.RoleCashier), controllers.TransitionOrder())
+	protected.POST("/orders/:order_id/fire", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleWaiter), controllers.FireCourse())
+	protected.POST("/orders/:order_id/cancel", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleWaiter), controllers.CancelOrder())
+	protected.POST("/orders/:order_id/transfer", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleWaiter), controllers.TransferOrder())
+	protected.POST("/orders/merge", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleWaiter), controllers.MergeOrders())
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"restaurant-management/controllers"
+	"restaurant-management/middleware"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ReportRoutes(public, protected *gin.RouterGroup) {
+	protected.GET("/reports/voids", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), controllers.GetVoidStats())
+}
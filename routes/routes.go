@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register mounts every route group on the router. Routes on the public group
+// (signup, login, token refresh and guest menu browsing) are reachable without
+// a token; everything else goes through middleware.Authentication. Apart from
+// managing their own account, users must confirm their email first and, when
+// their role requires it, enroll in two-factor authentication.
+func Register(router *gin.Engine) {
+	public := router.Group("/")
+
+	protected := router.Group("/")
+	protected.Use(middleware.Authentication())
+
+	verified := protected.Group("/")
+	verified.Use(middleware.RequireVerifiedEmail(), middleware.RequireTwoFactorEnrollment())
+
+	UserRoutes(public, protected)
+	DeviceRoutes(public, verified)
+	KeyRoutes(public, verified)
+	AdminRoutes(public, verified)
+	MenuRoutes(public, verified)
+	FoodRoutes(public, verified)
+	TableRoutes(public, verified)
+	OrderRoutes(public, verified)
+	OrderItemRoutes(public, verified)
+	InvoiceRoutes(public, verified)
+	NoteRoutes(public, verified)
+	KitchenRoutes(public, verified)
+	StationRoutes(public, verified)
+	ReportRoutes(public, verified)
+}
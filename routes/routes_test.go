@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// publicRoutes is every endpoint reachable without a token. Adding to it is
+// a deliberate decision; any other route has to reject anonymous callers.
+var publicRoutes = map[string]bool{
+	"GET /.well-known/jwks.json":     true,
+	"GET /foods":                     true,
+	"GET /foods/:food_id":            true,
+	"GET /menus":                     true,
+	"GET /menus/:menu_id":            true,
+	"POST /users/signup":             true,
+	"POST /users/login":              true,
+	"POST /users/login/2fa":          true,
+	"POST /users/pin-login":          true,
+	"POST /users/refresh":            true,
+	"POST /users/password/forgot":    true,
+	"POST /users/password/reset":     true,
+	"POST /users/verify-email":       true,
+	"POST /users/invitations/accept": true,
+}
+
+var pathParam = regexp.MustCompile(`[:*][^/]+`)
+
+func TestOnlyPublicRoutesSkipAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	Register(router)
+
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		key := route.Method + " " + route.Path
+		registered[key] = true
+
+		if publicRoutes[key] {
+			continue
+		}
+
+		req := httptest.NewRequest(route.Method, pathParam.ReplaceAllString(route.Path, "x"), nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s answered %d without a token; protect it or add it to publicRoutes", key, rec.Code)
+		}
+	}
+
+	for key := range publicRoutes {
+		if !registered[key] {
+			t.Errorf("public route %s is not registered", key)
+		}
+	}
+}
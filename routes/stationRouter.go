@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"restaurant-management/controllers"
+	"restaurant-management/middleware"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func StationRoutes(public, protected *gin.RouterGroup) {
+	protected.GET("/stations", controllers.GetStations())
+	protected.POST("/stations", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), middleware.Idempotency(), controllers.CreateStation())
+	protected.PATCH("/stations/:station_id", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), controllers.UpdateStation())
+	protected.GET("/kitchen/stations", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleKitchen), controllers.GetStationCounts())
+	protected.GET("/kitchen/expo", middleware.RequireRoles(models.RoleAdmin, models.RoleManager, models.RoleKitchen, models.RoleWaiter), controllers.GetExpo())
+}
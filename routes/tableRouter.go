@@ -1,12 +1,16 @@
 package routes
 
 import (
-	"github.com/gin-gonic/gin"
 	"restaurant-management/controllers"
+	"restaurant-management/middleware"
+	"restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
 )
-func TableRoutes(router *gin.Engine) {
-	router.GET("/tables" , controllers.GetTables())
-	router.GET("/tables/:table_id", controllers.GetTableById())
-	router.POST("/tables", controllers.CreateTable())
-	router.PATCH("/tables/:table_id", controllers.UpdateTable())
-}
\ No newline at end of file
+
+func TableRoutes(public, protected *gin.RouterGroup) {
+	protected.GET("/tables", controllers.GetTables())
+	protected.GET("/tables/:table_id", controllers.GetTableById())
+	protected.POST("/tables", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), middleware.Idempotency(), controllers.CreateTable())
+	protected.PATCH("/tables/:table_id", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), controllers.UpdateTable())
+}
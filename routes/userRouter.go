@@ -2,13 +2,37 @@ package routes
 
 import (
 	"restaurant-management/controllers"
+	"restaurant-management/middleware"
+	"restaurant-management/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func UserRoutes(router *gin.Engine) {
-		router.GET("/users" , controllers.GetUsers())
-		router.GET("/users/:user_id" , controllers.GetUserById())
-		router.POST("/users/signup" , controllers.SignUp())
-		router.POST("/users/login" , controllers.Login())
-}
\ No newline at end of file
+func UserRoutes(public, protected *gin.RouterGroup) {
+	protected.GET("/users", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), controllers.GetUsers())
+	protected.GET("/users/:user_id", middleware.RequireSelfOrRoles("user_id", models.RoleAdmin, models.RoleManager), controllers.GetUserById())
+	protected.GET("/users/:user_id/profile", controllers.GetUserProfile())
+	public.POST("/users/signup", controllers.SignUp())
+	public.POST("/users/login", controllers.Login())
+	public.POST("/users/login/2fa", controllers.VerifyLoginChallenge())
+	public.POST("/users/refresh", controllers.RefreshToken())
+	public.POST("/users/password/forgot", controllers.ForgotPassword())
+	public.POST("/users/password/reset", controllers.ResetPassword())
+	public.POST("/users/verify-email", controllers.VerifyEmail())
+	public.POST("/users/invitations/accept", controllers.AcceptInvitation())
+	protected.POST("/users/invitations", middleware.RequireRoles(models.RoleAdmin), controllers.InviteUser())
+	protected.POST("/users/:user_id/invitations/resend", middleware.RequireRoles(models.RoleAdmin), controllers.ResendInvitation())
+	protected.PATCH("/users/:user_id", middleware.RequireSelfOrRoles("user_id", models.RoleAdmin), controllers.UpdateUser())
+	protected.POST("/users/:user_id/deactivate", middleware.RequireRoles(models.RoleAdmin), controllers.DeactivateUser())
+	protected.POST("/users/:user_id/reactivate", middleware.RequireRoles(models.RoleAdmin), controllers.ReactivateUser())
+	protected.POST("/users/verify-email/resend", controllers.ResendVerificationEmail())
+	protected.POST("/users/logout", controllers.Logout())
+	protected.POST("/users/:user_id/sessions/revoke-all", middleware.RequireSelfOrRoles("user_id", models.RoleAdmin), controllers.RevokeAllSessions())
+	protected.POST("/users/:user_id/password", middleware.RequireSelfOrRoles("user_id", models.RoleAdmin), controllers.ChangePassword())
+	protected.POST("/users/:user_id/unlock", middleware.RequireRoles(models.RoleAdmin), controllers.UnlockUser())
+	protected.GET("/users/lockout-events", middleware.RequireRoles(models.RoleAdmin), controllers.GetLockoutEvents())
+	protected.POST("/users/:user_id/2fa/setup", middleware.RequireSelfOrRoles("user_id"), controllers.SetupTwoFactor())
+	protected.POST("/users/:user_id/2fa/enable", middleware.RequireSelfOrRoles("user_id"), controllers.EnableTwoFactor())
+	protected.POST("/users/:user_id/2fa/disable", middleware.RequireSelfOrRoles("user_id", models.RoleAdmin), controllers.DisableTwoFactor())
+	protected.PATCH("/users/:user_id/role", middleware.RequireRoles(models.RoleAdmin), controllers.UpdateUserRole())
+}